@@ -2,9 +2,16 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/derekparker/delve/service/api"
 )
@@ -13,10 +20,141 @@ type frozenBreakpoint struct {
 	Bp             api.Breakpoint
 	LineInFunction int
 	LineContents   string
+	// breakpoint was disabled by the user and does not exist in delve
+	Disabled bool
+	Extra    breakpointExtra
 }
 
 var FrozenBreakpoints []frozenBreakpoint
 
+type hitCondOp int
+
+const (
+	hitCondNone hitCondOp = iota
+	hitCondEq
+	hitCondGeq
+	hitCondMod
+)
+
+var hitCondOps = []string{"any", "==", ">=", "%"}
+
+// Breakpoint properties implemented by gdlv instead of delve
+type breakpointExtra struct {
+	// stop only when the hit count of the breakpoint satisfies HitCondOp HitCondN
+	HitCondOp hitCondOp
+	HitCondN  int
+	// clear the breakpoint the first time it stops the program
+	Temporary bool
+}
+
+var breakpointExtras = struct {
+	mu sync.Mutex
+	m  map[int]breakpointExtra
+}{m: map[int]breakpointExtra{}}
+
+func getBreakpointExtra(id int) breakpointExtra {
+	breakpointExtras.mu.Lock()
+	defer breakpointExtras.mu.Unlock()
+	return breakpointExtras.m[id]
+}
+
+func setBreakpointExtra(id int, extra breakpointExtra) {
+	breakpointExtras.mu.Lock()
+	defer breakpointExtras.mu.Unlock()
+	if extra == (breakpointExtra{}) {
+		delete(breakpointExtras.m, id)
+	} else {
+		breakpointExtras.m[id] = extra
+	}
+}
+
+func (extra breakpointExtra) String() string {
+	var v []string
+	if extra.HitCondOp != hitCondNone {
+		v = append(v, fmt.Sprintf("hit count %s %d", hitCondOps[extra.HitCondOp], extra.HitCondN))
+	}
+	if extra.Temporary {
+		v = append(v, "temporary")
+	}
+	return strings.Join(v, ", ")
+}
+
+// Parses a hit condition in the form ==N, >=N or %N
+func parseHitCondition(s string) (hitCondOp, int, error) {
+	for op := hitCondEq; op <= hitCondMod; op++ {
+		if !strings.HasPrefix(s, hitCondOps[op]) {
+			continue
+		}
+		n, err := strconv.Atoi(s[len(hitCondOps[op]):])
+		if err != nil {
+			return hitCondNone, 0, fmt.Errorf("wrong hit condition %q: %v", s, err)
+		}
+		if err := checkHitCondition(op, n); err != nil {
+			return hitCondNone, 0, fmt.Errorf("wrong hit condition %q: %v", s, err)
+		}
+		return op, n, nil
+	}
+	return hitCondNone, 0, fmt.Errorf("wrong hit condition %q: must be ==N, >=N or %%N", s)
+}
+
+// checkHitCondition returns an error if the hit condition can never be
+// satisfied, hit counts start at 1.
+func checkHitCondition(op hitCondOp, n int) error {
+	if op != hitCondNone && n <= 0 {
+		return errors.New("N must be positive")
+	}
+	return nil
+}
+
+func (extra breakpointExtra) hitConditionMet(bp *api.Breakpoint) bool {
+	n := bp.TotalHitCount
+	switch extra.HitCondOp {
+	case hitCondEq:
+		return n == uint64(extra.HitCondN)
+	case hitCondGeq:
+		return n >= uint64(extra.HitCondN)
+	case hitCondMod:
+		return extra.HitCondN > 0 && n%uint64(extra.HitCondN) == 0
+	default:
+		return true
+	}
+}
+
+// Returns true if the program stopped only at breakpoints whose hit
+// condition is not satisfied
+func hitConditionsUnmet(state *api.DebuggerState) bool {
+	found := false
+	for _, th := range state.Threads {
+		if th.Breakpoint == nil {
+			continue
+		}
+		found = true
+		if getBreakpointExtra(th.Breakpoint.ID).hitConditionMet(th.Breakpoint) {
+			return false
+		}
+	}
+	return found
+}
+
+// Clears all temporary breakpoints that stopped the program
+func clearTemporaryBreakpoints(out io.Writer, state *api.DebuggerState) {
+	cleared := map[int]bool{}
+	for _, th := range state.Threads {
+		bp := th.Breakpoint
+		if bp == nil || cleared[bp.ID] || !getBreakpointExtra(bp.ID).Temporary {
+			continue
+		}
+		cleared[bp.ID] = true
+		if _, err := client.ClearBreakpoint(bp.ID); err != nil {
+			fmt.Fprintf(out, "Could not clear temporary %s: %v\n", formatBreakpointName(bp, false), err)
+			continue
+		}
+		removeFrozenBreakpoint(bp)
+		setBreakpointExtra(bp.ID, breakpointExtra{})
+		fmt.Fprintf(out, "Temporary %s cleared\n", formatBreakpointName(bp, false))
+	}
+}
+
 // Saves position information for bp in FrozenBreakpoints
 func freezeBreakpoint(out io.Writer, bp *api.Breakpoint) {
 	if bp == nil || bp.ID < 0 || bp.FunctionName == "" || bp.File == "" {
@@ -74,18 +212,27 @@ func removeFrozenBreakpoint(bp *api.Breakpoint) {
 	if bp == nil {
 		return
 	}
+	setBreakpointExtra(bp.ID, breakpointExtra{})
 	for i := range FrozenBreakpoints {
-		if FrozenBreakpoints[i].Bp.ID == bp.ID {
-			copy(FrozenBreakpoints[i:], FrozenBreakpoints[i+1:])
-			FrozenBreakpoints = FrozenBreakpoints[:len(FrozenBreakpoints)-1]
+		if !FrozenBreakpoints[i].Disabled && FrozenBreakpoints[i].Bp.ID == bp.ID {
+			deleteFrozenBreakpoint(i)
 			break
 		}
 	}
 }
 
+func deleteFrozenBreakpoint(i int) {
+	copy(FrozenBreakpoints[i:], FrozenBreakpoints[i+1:])
+	FrozenBreakpoints = FrozenBreakpoints[:len(FrozenBreakpoints)-1]
+	saveFrozenBreakpoints()
+}
+
 // Collect breakpoint configuration of all frozen breakpoints
 func updateFrozenBreakpoints() {
 	for i := range FrozenBreakpoints {
+		if FrozenBreakpoints[i].Disabled {
+			continue
+		}
 		bp, err := client.GetBreakpoint(FrozenBreakpoints[i].Bp.ID)
 		if err == nil {
 			FrozenBreakpoints[i].Bp = *bp
@@ -96,49 +243,185 @@ func updateFrozenBreakpoints() {
 // Clears all breakpoints in FrozenBreakpoints
 func clearFrozenBreakpoints() {
 	for _, fbp := range FrozenBreakpoints {
-		client.ClearBreakpoint(fbp.Bp.ID)
+		if !fbp.Disabled {
+			client.ClearBreakpoint(fbp.Bp.ID)
+		}
 	}
 }
 
 func restoreFrozenBreakpoints(out io.Writer) {
-	// Restore frozen breakpoints
+	// Restore frozen breakpoints, breakpoints that can not be restored are
+	// kept as disabled so that they aren't lost
+	var disabled []frozenBreakpoint
+	restored := map[int]frozenBreakpoint{}
 	for _, fbp := range FrozenBreakpoints {
-		fbp.Restore(out)
+		if fbp.Disabled {
+			disabled = append(disabled, fbp)
+			continue
+		}
+		if bp := fbp.Restore(out); bp != nil {
+			setBreakpointExtra(bp.ID, fbp.Extra)
+			restored[bp.ID] = fbp
+		} else {
+			fbp.Disabled = true
+			disabled = append(disabled, fbp)
+		}
 	}
 
 	// Re-freeze breakpoints
 	FrozenBreakpoints = FrozenBreakpoints[:0]
+	bps, err := client.ListBreakpoints()
+	if err != nil {
+		return
+	}
+	for _, bp := range bps {
+		if bp.ID < 0 {
+			continue
+		}
+		n := len(FrozenBreakpoints)
+		freezeBreakpoint(out, bp)
+		if fbp, ok := restored[bp.ID]; ok && len(FrozenBreakpoints) == n {
+			// keep the position recorded by the previous session
+			fbp.Bp.ID = bp.ID
+			FrozenBreakpoints = append(FrozenBreakpoints, fbp)
+		}
+	}
+	FrozenBreakpoints = append(FrozenBreakpoints, disabled...)
+	saveFrozenBreakpoints()
+}
+
+// savedBreakpointsLoaded is set after the breakpoints file is read, when
+// gdlv reconnects to a new delve process FrozenBreakpoints already
+// contains the breakpoints to restore.
+var savedBreakpointsLoaded bool
+
+// Restores the breakpoints saved by a previous session on the same project,
+// or the ones of the previous delve process after it was restarted
+func restoreSavedBreakpoints(out io.Writer) {
 	bps, err := client.ListBreakpoints()
 	if err != nil {
 		return
 	}
 	for _, bp := range bps {
 		if bp.ID >= 0 {
-			freezeBreakpoint(out, bp)
+			// connected to a delve instance that already has breakpoints
+			return
+		}
+	}
+
+	if !savedBreakpointsLoaded {
+		savedBreakpointsLoaded = true
+		if BackendServer.project == "" {
+			return
+		}
+		m, err := loadBreakpointsFile()
+		if err != nil {
+			fmt.Fprintf(out, "Could not load saved breakpoints: %v\n", err)
+			return
 		}
+		FrozenBreakpoints = m[BackendServer.project]
+		if len(FrozenBreakpoints) > 0 {
+			fmt.Fprintf(out, "Restoring %d breakpoints from previous session\n", len(FrozenBreakpoints))
+		}
+	}
+	if len(FrozenBreakpoints) == 0 {
+		return
 	}
+	restoreFrozenBreakpoints(out)
 }
 
-func (fbp *frozenBreakpoint) Restore(out io.Writer) {
-	if fbp.Bp.FunctionName == "" || fbp.Bp.File == "" {
+func loadBreakpointsFile() (map[string][]frozenBreakpoint, error) {
+	m := map[string][]frozenBreakpoint{}
+	fh, err := os.Open(breakpointsLoc())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+	defer fh.Close()
+	if err := json.NewDecoder(fh).Decode(&m); err != nil {
+		return nil, fmt.Errorf("%s: %v", breakpointsLoc(), err)
+	}
+	return m, nil
+}
+
+// Saves FrozenBreakpoints to the breakpoints file, replacing the ones
+// previously saved for the current project
+func saveFrozenBreakpoints() {
+	for i := range FrozenBreakpoints {
+		if !FrozenBreakpoints[i].Disabled {
+			FrozenBreakpoints[i].Extra = getBreakpointExtra(FrozenBreakpoints[i].Bp.ID)
+		}
+	}
+	if BackendServer.project == "" {
 		return
 	}
+	if err := writeBreakpointsFile(); err != nil {
+		// the caller could be holding mu
+		go func() {
+			scrollbackOut := editorWriter{&scrollbackEditor, true}
+			fmt.Fprintf(&scrollbackOut, "Could not save breakpoints: %v\n", err)
+		}()
+	}
+}
+
+// writeBreakpointsFile replaces the breakpoints file atomically, it refuses
+// to overwrite a file that can't be read to avoid losing the breakpoints
+// of other projects.
+func writeBreakpointsFile() error {
+	m, err := loadBreakpointsFile()
+	if err != nil {
+		return err
+	}
+	if len(FrozenBreakpoints) > 0 {
+		m[BackendServer.project] = FrozenBreakpoints
+	} else {
+		delete(m, BackendServer.project)
+	}
+	loc := breakpointsLoc()
+	fh, err := ioutil.TempFile(filepath.Dir(loc), filepath.Base(loc))
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(fh).Encode(m)
+	if err2 := fh.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(fh.Name(), loc)
+	}
+	if err != nil {
+		os.Remove(fh.Name())
+	}
+	return err
+}
+
+// Creates a breakpoint from fbp, returns nil if the breakpoint could not be created
+func (fbp *frozenBreakpoint) Restore(out io.Writer) *api.Breakpoint {
+	if fbp.Bp.FunctionName == "" || fbp.Bp.File == "" {
+		return nil
+	}
+
+	// fbp must not be modified, it is kept if the breakpoint can't be
+	// restored
+	req := fbp.Bp
 
 	if fbp.LineInFunction == 0 {
-		fbp.Bp.Addr = 0
-		fbp.Bp.File = ""
-		fbp.Bp.Line = 0
-		_, err := client.CreateBreakpoint(&fbp.Bp)
+		req.Addr = 0
+		req.File = ""
+		req.Line = 0
+		bp, err := client.CreateBreakpoint(&req)
 		if err != nil {
 			fmt.Fprintf(out, "Could not restore breakpoint at function %s: %v\n", fbp.Bp.FunctionName, err)
 		}
-		return
+		return bp
 	}
 
 	locs, err := client.FindLocation(api.EvalScope{-1, 0}, fbp.Bp.FunctionName)
 	if err != nil || len(locs) != 1 || locs[0].Function == nil || locs[0].Function.Name != fbp.Bp.FunctionName {
 		fmt.Fprintf(out, "Could not restore breakpoint %d, function not found\n", fbp.Bp.ID)
-		return
+		return nil
 	}
 	functionLoc := locs[0]
 
@@ -147,7 +430,7 @@ func (fbp *frozenBreakpoint) Restore(out io.Writer) {
 
 	fh, err := os.Open(functionLoc.File)
 	if err != nil {
-		return
+		return nil
 	}
 	defer fh.Close()
 
@@ -176,19 +459,92 @@ func (fbp *frozenBreakpoint) Restore(out io.Writer) {
 		bestMatch = functionLoc.Line + fbp.LineInFunction
 	}
 
-	fbp.Bp.Addr = 0
-	fbp.Bp.FunctionName = ""
-	fbp.Bp.File = functionLoc.File
-	fbp.Bp.Line = bestMatch
+	req.Addr = 0
+	req.FunctionName = ""
+	req.File = functionLoc.File
+	req.Line = bestMatch
 
-	bp, err := client.CreateBreakpoint(&fbp.Bp)
+	bp, err := client.CreateBreakpoint(&req)
 	if err != nil {
-		fmt.Fprintf(out, "Could not restore breakpoint at %s:%d: %v\n", fbp.Bp.File, fbp.Bp.Line, err)
-		return
+		fmt.Fprintf(out, "Could not restore breakpoint at %s:%d: %v\n", req.File, req.Line, err)
+		return nil
 	}
 
 	if bp.FunctionName != functionLoc.Function.Name {
 		client.ClearBreakpoint(bp.ID)
 		fmt.Fprintf(out, "Could not restore breakpoint %d (function name mismatch)\n", fbp.Bp.ID)
+		return nil
+	}
+	return bp
+}
+
+// Returns the index in FrozenBreakpoints of the disabled breakpoint with the specified name or ID
+func findDisabledBreakpoint(arg string) int {
+	id, err := strconv.Atoi(arg)
+	for i := range FrozenBreakpoints {
+		if !FrozenBreakpoints[i].Disabled {
+			continue
+		}
+		if (err == nil && FrozenBreakpoints[i].Bp.ID == id) || (err != nil && FrozenBreakpoints[i].Bp.Name == arg) {
+			return i
+		}
+	}
+	return -1
+}
+
+func disabledBreakpoints() []*api.Breakpoint {
+	var r []*api.Breakpoint
+	for i := range FrozenBreakpoints {
+		if FrozenBreakpoints[i].Disabled {
+			bp := FrozenBreakpoints[i].Bp
+			r = append(r, &bp)
+		}
+	}
+	return r
+}
+
+// Clears bp from delve, keeping its configuration in FrozenBreakpoints so
+// that it can be re-enabled later
+func disableBreakpoint(out io.Writer, bp *api.Breakpoint) error {
+	idx := func() int {
+		for i := range FrozenBreakpoints {
+			if !FrozenBreakpoints[i].Disabled && FrozenBreakpoints[i].Bp.ID == bp.ID {
+				return i
+			}
+		}
+		return -1
+	}
+
+	i := idx()
+	if i < 0 {
+		freezeBreakpoint(out, bp)
+		i = idx()
+		if i < 0 {
+			return errors.New("can not disable breakpoint, position could not be recorded")
+		}
+	}
+
+	if _, err := client.ClearBreakpoint(bp.ID); err != nil {
+		return err
+	}
+	FrozenBreakpoints[i].Bp = *bp
+	FrozenBreakpoints[i].Extra = getBreakpointExtra(bp.ID)
+	FrozenBreakpoints[i].Disabled = true
+	setBreakpointExtra(bp.ID, breakpointExtra{})
+	saveFrozenBreakpoints()
+	return nil
+}
+
+// Re-creates the disabled breakpoint at index i of FrozenBreakpoints
+func enableBreakpoint(out io.Writer, i int) (*api.Breakpoint, error) {
+	fbp := FrozenBreakpoints[i]
+	bp := fbp.Restore(out)
+	if bp == nil {
+		return nil, errors.New("could not enable breakpoint")
 	}
+	setBreakpointExtra(bp.ID, fbp.Extra)
+	deleteFrozenBreakpoint(i)
+	freezeBreakpoint(out, bp)
+	saveFrozenBreakpoints()
+	return bp, nil
 }
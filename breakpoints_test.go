@@ -0,0 +1,63 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"testing"
+
+	"github.com/derekparker/delve/service/api"
+)
+
+func TestParseHitCondition(t *testing.T) {
+	tests := []struct {
+		in  string
+		op  hitCondOp
+		n   int
+		err bool
+	}{
+		{"==3", hitCondEq, 3, false},
+		{">=10", hitCondGeq, 10, false},
+		{"%2", hitCondMod, 2, false},
+		{"==0", hitCondNone, 0, true},
+		{"%0", hitCondNone, 0, true},
+		{">=-1", hitCondNone, 0, true},
+		{"==", hitCondNone, 0, true},
+		{"== 3", hitCondNone, 0, true},
+		{"3", hitCondNone, 0, true},
+		{"<3", hitCondNone, 0, true},
+	}
+	for _, tc := range tests {
+		op, n, err := parseHitCondition(tc.in)
+		if (err != nil) != tc.err {
+			t.Errorf("parseHitCondition(%q): unexpected error %v", tc.in, err)
+			continue
+		}
+		if op != tc.op || n != tc.n {
+			t.Errorf("parseHitCondition(%q) = %v %d, expected %v %d", tc.in, op, n, tc.op, tc.n)
+		}
+	}
+}
+
+func TestHitConditionMet(t *testing.T) {
+	tests := []struct {
+		op   hitCondOp
+		n    int
+		hits uint64
+		met  bool
+	}{
+		{hitCondNone, 0, 1, true},
+		{hitCondEq, 3, 2, false},
+		{hitCondEq, 3, 3, true},
+		{hitCondEq, 3, 4, false},
+		{hitCondGeq, 3, 2, false},
+		{hitCondGeq, 3, 5, true},
+		{hitCondMod, 2, 3, false},
+		{hitCondMod, 2, 4, true},
+	}
+	for _, tc := range tests {
+		extra := breakpointExtra{HitCondOp: tc.op, HitCondN: tc.n}
+		if met := extra.hitConditionMet(&api.Breakpoint{TotalHitCount: tc.hits}); met != tc.met {
+			t.Errorf("%s with %d hits: got %v, expected %v", extra, tc.hits, met, tc.met)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aarzilli/nucular"
+	"github.com/derekparker/delve/service/api"
+)
+
+// buildError is an error reported by the compiler, file and line are empty
+// for messages that don't refer to a location.
+type buildError struct {
+	file      string
+	line, col int
+	msg       string
+}
+
+func (e *buildError) String() string {
+	switch {
+	case e.file == "":
+		return e.msg
+	case e.col > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", e.file, e.line, e.col, e.msg)
+	default:
+		return fmt.Sprintf("%s:%d: %s", e.file, e.line, e.msg)
+	}
+}
+
+// buildPanel is protected by mu.
+var buildPanel = struct {
+	built  bool
+	ok     bool
+	time   time.Time
+	errors []buildError
+}{}
+
+var buildFailedColor = color.RGBA{0xff, 0x00, 0x00, 0xff}
+
+var buildErrorRx = regexp.MustCompile(`^(.+?\.(?:go|s|c|h)):(\d+)(?::(\d+))?: (.*)$`)
+
+// parseBuildOutput parses the output of 'go build' into a list of errors.
+func parseBuildOutput(out string) []buildError {
+	var r []buildError
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.TrimSpace(line) == "" || line[0] == '#':
+			// package headers
+		case (line[0] == '\t' || line[0] == ' ') && len(r) > 0:
+			r[len(r)-1].msg += "\n" + strings.TrimSpace(line)
+		default:
+			m := buildErrorRx.FindStringSubmatch(line)
+			if m == nil {
+				r = append(r, buildError{msg: line})
+				continue
+			}
+			e := buildError{file: m[1], msg: m[4]}
+			e.line, _ = strconv.Atoi(m[2])
+			e.col, _ = strconv.Atoi(m[3])
+			r = append(r, e)
+		}
+	}
+	return r
+}
+
+// setBuildResult records the result of a build and writes it to sw.
+func setBuildResult(sw io.Writer, out string, err error) {
+	mu.Lock()
+	buildPanel.built = true
+	buildPanel.ok = err == nil
+	buildPanel.time = time.Now()
+	buildPanel.errors = parseBuildOutput(out)
+	if err != nil && len(buildPanel.errors) == 0 {
+		buildPanel.errors = []buildError{{msg: err.Error()}}
+	}
+	shown := rootPanel.shows(infoBuild)
+	mu.Unlock()
+	wnd.Changed()
+
+	if shown {
+		if err != nil {
+			fmt.Fprintf(sw, "Build failed, see the Build panel\n")
+		}
+		return
+	}
+	if err != nil {
+		out += fmt.Sprintf("\n%v\n", err)
+	}
+	io.WriteString(sw, out)
+}
+
+// openBuildError shows the location of e in the listing panel.
+func openBuildError(e buildError) {
+	path := e.file
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	loc := &api.Location{File: path, Line: e.line}
+	if client != nil {
+		listingPanel.pinnedLoc = loc
+		refreshState(refreshToSameFrame, clearNothing, nil)
+		return
+	}
+
+	// the build failed before delve could start, there is no state to refresh
+	mu.Lock()
+	defer mu.Unlock()
+	defer wnd.Changed()
+	listingPanel.pinnedLoc = loc
+	listingPanel.listing = listingPanel.listing[:0]
+	listingPanel.text = nil
+	listingPanel.recenterListing = true
+	if pos, err := loadListing(loc, nil, time.Time{}); err != nil {
+		scrollbackOut := editorWriter{&scrollbackEditor, false}
+		fmt.Fprintf(&scrollbackOut, "Error opening %s %s: %v\n", path, pos, err)
+	}
+}
+
+func updateBuild(container *nucular.Window) {
+	w := container.GroupBegin("build", 0)
+	if w == nil {
+		return
+	}
+	defer w.GroupEnd()
+
+	w.MenubarBegin()
+	w.Row(varRowHeight).Static(0, 100, 150)
+	switch {
+	case BackendServer.buildcmd == nil:
+		w.Label("The target is not built by gdlv", "LC")
+	case !buildPanel.built:
+		w.Label("Not built yet", "LC")
+	case buildPanel.ok:
+		w.Label(fmt.Sprintf("Build succeeded at %s", buildPanel.time.Format("15:04:05")), "LC")
+	default:
+		w.LabelColored(fmt.Sprintf("Build failed at %s", buildPanel.time.Format("15:04:05")), "LC", buildFailedColor)
+	}
+	// the executable can only be replaced while delve isn't using it
+	if BackendServer.buildcmd != nil && !running {
+		if w.ButtonText("Rebuild") {
+			go BackendServer.Rebuild()
+		}
+	} else {
+		w.Spacing(1)
+	}
+	if BackendServer.buildcmd != nil && !running && client != nil && !BackendServer.core {
+		if w.ButtonText("Rebuild and restart") {
+			scrollbackOut := editorWriter{&scrollbackEditor, false}
+			fmt.Fprintf(&scrollbackOut, "%s restart\n", currentPrompt())
+			go executeCommand("restart")
+		}
+	} else {
+		w.Spacing(1)
+	}
+	w.MenubarEnd()
+
+	for _, e := range buildPanel.errors {
+		lines := strings.Split(e.String(), "\n")
+		w.Row(varRowHeight).Dynamic(1)
+		selected := false
+		if w.SelectableLabel(lines[0], "LC", &selected) && e.file != "" {
+			go openBuildError(e)
+		}
+		for _, line := range lines[1:] {
+			w.Row(varRowHeight).Dynamic(1)
+			w.Label("    "+line, "LC")
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBuildOutput(t *testing.T) {
+	tests := []struct {
+		out    string
+		errors []buildError
+	}{
+		{"", nil},
+		{"# example.com/pkg\n./main.go:10:5: undefined: foo\n", []buildError{{file: "./main.go", line: 10, col: 5, msg: "undefined: foo"}}},
+		{"main.go:3: syntax error\n", []buildError{{file: "main.go", line: 3, msg: "syntax error"}}},
+		{
+			"./a.go:1:2: cannot use x (type int) as type string\n\tin argument to f\n./b.s:7: bad instruction\n",
+			[]buildError{
+				{file: "./a.go", line: 1, col: 2, msg: "cannot use x (type int) as type string\nin argument to f"},
+				{file: "./b.s", line: 7, msg: "bad instruction"},
+			},
+		},
+		{"can't load package: package foo: cannot find package\n", []buildError{{msg: "can't load package: package foo: cannot find package"}}},
+		{"/abs/path/x.go:12:1: missing return\n", []buildError{{file: "/abs/path/x.go", line: 12, col: 1, msg: "missing return"}}},
+	}
+	for _, tc := range tests {
+		if errors := parseBuildOutput(tc.out); !reflect.DeepEqual(errors, tc.errors) {
+			t.Errorf("parseBuildOutput(%q) = %#v, expected %#v", tc.out, errors, tc.errors)
+		}
+	}
+}
+
+func TestBuildErrorString(t *testing.T) {
+	tests := []struct {
+		e   buildError
+		out string
+	}{
+		{buildError{msg: "failed"}, "failed"},
+		{buildError{file: "a.go", line: 3, msg: "bad"}, "a.go:3: bad"},
+		{buildError{file: "a.go", line: 3, col: 4, msg: "bad"}, "a.go:3:4: bad"},
+	}
+	for _, tc := range tests {
+		if out := tc.e.String(); out != tc.out {
+			t.Errorf("%#v.String() = %q, expected %q", tc.e, out, tc.out)
+		}
+	}
+}
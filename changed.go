@@ -0,0 +1,212 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"image/color"
+	"reflect"
+	"sync"
+
+	"github.com/derekparker/delve/service/api"
+)
+
+// Variables loaded at each stop are compared with the values they had at
+// the previous stop, in the same goroutine and function, so that the parts
+// that changed can be highlighted.
+
+var changedColor = color.RGBA{0xff, 0xa0, 0x40, 0xff}
+
+type variablesKind uint8
+
+const (
+	localVariables variablesKind = iota
+	globalVariables
+	exprVariables
+	numVariablesKinds
+)
+
+type variablesScope struct {
+	kind variablesKind
+	gid  int
+	fn   string
+}
+
+type variablesSnapshot struct {
+	stop      int
+	cur, prev map[string]*api.Variable
+}
+
+var changedVars = struct {
+	mu        sync.Mutex
+	stop      int
+	snapshots map[variablesScope]*variablesSnapshot
+	// marks maps changed variables to their previous value
+	marks [numVariablesKinds]map[*api.Variable]*api.Variable
+}{
+	snapshots: map[variablesScope]*variablesSnapshot{},
+}
+
+// changedVarsStop is called every time the target stops, after it the
+// values recorded so far become the previous values. Snapshots of
+// goroutines that no longer exist are discarded.
+func changedVarsStop() {
+	changedVars.mu.Lock()
+	changedVars.stop++
+	n := len(changedVars.snapshots)
+	changedVars.mu.Unlock()
+
+	if n == 0 {
+		return
+	}
+	gs, err := client.ListGoroutines()
+	if err != nil {
+		return
+	}
+	live := map[int]bool{-1: true}
+	for _, g := range gs {
+		live[g.ID] = true
+	}
+
+	changedVars.mu.Lock()
+	for scope := range changedVars.snapshots {
+		if !live[scope.gid] {
+			delete(changedVars.snapshots, scope)
+		}
+	}
+	changedVars.mu.Unlock()
+}
+
+// currentVariablesScope returns the scope of variables of the specified
+// kind for the goroutine and frame returned by evalScope.
+func currentVariablesScope(kind variablesKind) variablesScope {
+	if kind == globalVariables {
+		return variablesScope{kind: kind, gid: -1}
+	}
+	es := evalScope()
+	scope := variablesScope{kind: kind, gid: es.GoroutineID}
+	frames, err := client.Stacktrace(es.GoroutineID, es.Frame, nil)
+	if err == nil && es.Frame < len(frames) && frames[es.Frame].Function != nil {
+		scope.fn = frames[es.Frame].Function.Name
+	}
+	return scope
+}
+
+// resetChangedMarks forgets which variables of the specified kind were
+// marked as changed.
+func resetChangedMarks(kind variablesKind) {
+	changedVars.mu.Lock()
+	changedVars.marks[kind] = map[*api.Variable]*api.Variable{}
+	changedVars.mu.Unlock()
+}
+
+// compareToSnapshot records v as the current value of name in scope and
+// marks the parts of v that are different from the value name had in the
+// previous stop.
+func compareToSnapshot(scope variablesScope, name string, v *api.Variable) {
+	if v == nil {
+		return
+	}
+
+	changedVars.mu.Lock()
+	defer changedVars.mu.Unlock()
+
+	s := changedVars.snapshots[scope]
+	if s == nil {
+		s = &variablesSnapshot{stop: changedVars.stop, cur: map[string]*api.Variable{}}
+		changedVars.snapshots[scope] = s
+	}
+	if s.stop != changedVars.stop {
+		s.stop = changedVars.stop
+		s.prev, s.cur = s.cur, map[string]*api.Variable{}
+	}
+
+	// showVariable modifies variables while displaying them, the snapshot
+	// needs its own copy
+	vcopy := copyVariable(v)
+	s.cur[name] = &vcopy
+
+	if changedVars.marks[scope.kind] == nil {
+		changedVars.marks[scope.kind] = map[*api.Variable]*api.Variable{}
+	}
+	if old := s.prev[name]; old != nil {
+		diffVariable(changedVars.marks[scope.kind], old, v)
+	}
+}
+
+// changedVariable returns the previous value of v if v changed since the
+// last stop, nil otherwise.
+func changedVariable(v *api.Variable) *api.Variable {
+	changedVars.mu.Lock()
+	defer changedVars.mu.Unlock()
+	for _, marks := range changedVars.marks {
+		if old := marks[v]; old != nil {
+			return old
+		}
+	}
+	return nil
+}
+
+func copyVariable(v *api.Variable) api.Variable {
+	r := *v
+	if v.Children != nil {
+		r.Children = make([]api.Variable, len(v.Children))
+		for i := range v.Children {
+			r.Children[i] = copyVariable(&v.Children[i])
+		}
+	}
+	return r
+}
+
+// diffVariable marks v and all its children that are different from old,
+// returns true if v changed.
+func diffVariable(marks map[*api.Variable]*api.Variable, old, v *api.Variable) bool {
+	if old.Kind != v.Kind || old.Type != v.Type || old.Unreadable != v.Unreadable {
+		marks[v] = old
+		return true
+	}
+
+	changed := old.Value != v.Value || old.Len != v.Len || old.Cap != v.Cap
+
+	switch v.Kind {
+	case reflect.Ptr, reflect.UnsafePointer:
+		if len(old.Children) != len(v.Children) || (len(v.Children) > 0 && old.Children[0].Addr != v.Children[0].Addr) {
+			changed = true
+		}
+	}
+
+	if v.Kind == reflect.Map {
+		// map children are alternating keys and values, the order of the
+		// entries can change between stops
+		oldValues := map[string]*api.Variable{}
+		for i := 0; i+1 < len(old.Children); i += 2 {
+			oldValues[variableValueString(&old.Children[i])] = &old.Children[i+1]
+		}
+		for i := 0; i+1 < len(v.Children); i += 2 {
+			if oldv := oldValues[variableValueString(&v.Children[i])]; oldv != nil && diffVariable(marks, oldv, &v.Children[i+1]) {
+				changed = true
+			}
+		}
+	} else {
+		n := len(v.Children)
+		if len(old.Children) < n {
+			n = len(old.Children)
+		}
+		for i := 0; i < n; i++ {
+			if diffVariable(marks, &old.Children[i], &v.Children[i]) {
+				changed = true
+			}
+		}
+	}
+
+	if changed {
+		marks[v] = old
+	}
+	return changed
+}
+
+func variableValueString(v *api.Variable) string {
+	if v.Unreadable != "" {
+		return "(unreadable " + v.Unreadable + ")"
+	}
+	return v.SinglelineString()
+}
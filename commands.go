@@ -28,6 +28,9 @@ type command struct {
 	complete func()
 	helpMsg  string
 	cmdFn    cmdfunc
+	// needsProcess is true for commands that run or modify the target or
+	// its breakpoints, which are not available when debugging a core file
+	needsProcess bool
 }
 
 // Returns true if the command string matches one of the aliases for this command
@@ -69,31 +72,127 @@ func DebugCommands() *Commands {
 	help [command]
 	
 Type "help" followed by the name of a command for more information about it.`},
-		{aliases: []string{"break", "b"}, cmdFn: breakpoint, complete: completeLocation, helpMsg: `Sets a breakpoint.
+		{aliases: []string{"break", "b"}, needsProcess: true, cmdFn: breakpoint, complete: completeLocation, helpMsg: `Sets a breakpoint.
 
-	break [name] <linespec>
+	break [-temp] [-hit <condition>] [name] <linespec>
 
 See $GOPATH/src/github.com/derekparker/delve/Documentation/cli/locspec.md for the syntax of linespec.
 
+Options:
+
+	-temp			Clears the breakpoint the first time it stops the program.
+	-hit <condition>	Stops only when the hit count of the breakpoint satisfies the condition, one of ==N, >=N or %N (every N hits).
+
 See also: "help on", "help cond" and "help clear"`},
-		{aliases: []string{"trace", "t"}, cmdFn: tracepoint, complete: completeLocation, helpMsg: `Set tracepoint.
+		{aliases: []string{"trace", "t"}, needsProcess: true, cmdFn: tracepoint, complete: completeLocation, helpMsg: `Set tracepoint.
 
 	trace [name] <linespec>
 	
 A tracepoint is a breakpoint that does not stop the execution of the program, instead when the tracepoint is hit a notification is displayed. See $GOPATH/src/github.com/derekparker/delve/Documentation/cli/locspec.md for the syntax of linespec.
 
 See also: "help on", "help cond" and "help clear"`},
-		{aliases: []string{"clear"}, cmdFn: clear, helpMsg: `Deletes breakpoint.
+		{aliases: []string{"clear"}, needsProcess: true, cmdFn: clear, helpMsg: `Deletes breakpoint.
 		
 			clear <breakpoint name or id>`},
-		{aliases: []string{"restart", "r"}, cmdFn: restart, helpMsg: "Restart process."},
-		{aliases: []string{"continue", "c"}, cmdFn: cont, helpMsg: "Run until breakpoint or program termination."},
-		{aliases: []string{"step", "s"}, cmdFn: step, helpMsg: "Single step through program."},
-		{aliases: []string{"step-instruction", "si"}, cmdFn: stepInstruction, helpMsg: "Single step a single cpu instruction."},
-		{aliases: []string{"next", "n"}, cmdFn: next, helpMsg: "Step over to next source line."},
-		{aliases: []string{"stepout"}, cmdFn: stepout, helpMsg: "Step out of the current function."},
-		{aliases: []string{"cancelnext"}, cmdFn: cancelnext, helpMsg: "Cancels the next operation currently in progress."},
-		{aliases: []string{"interrupt"}, cmdFn: interrupt, helpMsg: "interrupts execution."},
+		{aliases: []string{"enable"}, needsProcess: true, cmdFn: enableCommand, complete: completeDisabledBreakpoint, helpMsg: `Enables a disabled breakpoint.
+
+	enable <breakpoint name or id>`},
+		{aliases: []string{"disable"}, needsProcess: true, cmdFn: disableCommand, complete: completeBreakpoint, helpMsg: `Disables a breakpoint.
+
+	disable <breakpoint name or id>
+
+The breakpoint is removed from the program but its configuration (condition, print list, etc) is kept by gdlv and restored when the breakpoint is enabled again.`},
+		{aliases: []string{"on"}, needsProcess: true, cmdFn: onCommand, complete: completeOn, helpMsg: `Executes a command when a breakpoint is hit.
+
+	on <breakpoint name or id> <command>
+
+Supported commands:
+
+	print <expression>	Evaluates the expression and prints the result.
+	stack <n>		Prints a stacktrace n frames deep (0 disables it).
+	args [-v]		Prints the function arguments.
+	locals [-v]		Prints the local variables.
+	goroutine		Prints information about the current goroutine.
+
+The -v flag prints the arguments or local variables with the same verbosity as the print command.`},
+		{aliases: []string{"cond"}, needsProcess: true, cmdFn: condCommand, complete: completeCond, helpMsg: `Set breakpoint condition.
+
+	cond <breakpoint name or id> <boolean expression>
+
+Specifies that the breakpoint or tracepoint should break only if the boolean expression is true. An empty expression removes the condition.`},
+		{aliases: []string{"frame"}, cmdFn: frameCommand, helpMsg: `Selects a stack frame or executes a command in it.
+
+	frame <n> [command]
+
+Without a command the frame becomes the current frame, otherwise the command is executed with frame n as the current frame.`},
+		{aliases: []string{"up"}, cmdFn: upCommand, helpMsg: `Moves the current frame up.
+
+	up [n]
+
+Moves up n frames (default 1), towards the caller.`},
+		{aliases: []string{"down"}, cmdFn: downCommand, helpMsg: `Moves the current frame down.
+
+	down [n]
+
+Moves down n frames (default 1), towards the callee.`},
+		{aliases: []string{"goroutine"}, cmdFn: goroutineCommand, helpMsg: `Shows or changes the current goroutine.
+
+	goroutine
+	goroutine <id> [command]
+
+Without arguments prints the current goroutine, with a goroutine id switches to it. If a command is specified it is executed with goroutine id as the current goroutine, without switching to it.`},
+		{aliases: []string{"thread", "tr"}, cmdFn: threadCommand, helpMsg: `Shows or changes the current thread.
+
+	thread
+	thread <id>`},
+		{aliases: []string{"goroutines"}, cmdFn: goroutinesCommand, helpMsg: `Lists all goroutines.
+
+	goroutines [-u|-r|-g]
+
+Options:
+	-u	show the location of the topmost frame in user code
+	-r	show the location of the topmost frame (including frames inside private runtime functions)
+	-g	show the location of the go instruction that created the goroutine
+
+Without options the location selected in the goroutines panel is shown.`},
+		{aliases: []string{"stack", "bt"}, cmdFn: stackCommand, helpMsg: `Prints the stack trace of the current goroutine.
+
+	stack [depth] [-full]
+
+Depth defaults to the depth of the stacktrace panel, -full also prints the arguments and local variables of each frame.`},
+		{aliases: []string{"locals"}, cmdFn: localsCommand, helpMsg: `Prints the local variables of the current frame.
+
+	locals [-v] [filter]
+
+Only variables whose name contains filter are printed, -v prints them with the same verbosity as the print command.`},
+		{aliases: []string{"args"}, cmdFn: argsCommand, helpMsg: `Prints the arguments of the current frame.
+
+	args [-v] [filter]
+
+Only arguments whose name contains filter are printed, -v prints them with the same verbosity as the print command.`},
+		{aliases: []string{"regs"}, cmdFn: regsCommand, helpMsg: `Prints the CPU registers of the current thread.
+
+	regs [-a]
+
+The -a flag also prints floating point registers.`},
+		{aliases: []string{"breakpoints", "bp"}, cmdFn: breakpointsCommand, helpMsg: "Prints all breakpoints, including disabled ones."},
+		{aliases: []string{"restart", "r"}, needsProcess: true, cmdFn: restart, helpMsg: `Restart process.
+
+	restart [-- args...]
+
+If arguments are specified they replace the arguments passed to the program, "restart --" removes all arguments. Breakpoints are preserved.`},
+		{aliases: []string{"continue", "c"}, needsProcess: true, cmdFn: cont, helpMsg: "Run until breakpoint or program termination."},
+		{aliases: []string{"runto"}, needsProcess: true, cmdFn: runTo, complete: completeLocation, helpMsg: `Run until the specified location is reached.
+
+	runto <linespec>
+
+A temporary breakpoint is set at the specified location and removed as soon as the program stops, for any reason.`},
+		{aliases: []string{"step", "s"}, needsProcess: true, cmdFn: step, helpMsg: "Single step through program."},
+		{aliases: []string{"step-instruction", "si"}, needsProcess: true, cmdFn: stepInstruction, helpMsg: "Single step a single cpu instruction."},
+		{aliases: []string{"next", "n"}, needsProcess: true, cmdFn: next, helpMsg: "Step over to next source line."},
+		{aliases: []string{"stepout"}, needsProcess: true, cmdFn: stepout, helpMsg: "Step out of the current function."},
+		{aliases: []string{"cancelnext"}, needsProcess: true, cmdFn: cancelnext, helpMsg: "Cancels the next operation currently in progress."},
+		{aliases: []string{"interrupt"}, needsProcess: true, cmdFn: interrupt, helpMsg: "interrupts execution."},
 		{aliases: []string{"print", "p"}, complete: completeVariable, cmdFn: printVar, helpMsg: `Evaluate an expression.
 
 	print <expression>
@@ -104,7 +203,12 @@ See $GOPATH/src/github.com/derekparker/delve/Documentation/cli/expr.md for a des
 			list <linespec>
 		
 		See $GOPATH/src/github.com/derekparker/delve/Documentation/cli/expr.md for a description of supported expressions.`},
-		{aliases: []string{"set"}, cmdFn: setVar, complete: completeVariable, helpMsg: `Changes the value of a variable.
+		{aliases: []string{"x"}, cmdFn: examineMemoryCommand, complete: completeVariable, helpMsg: `Examines memory.
+
+	x <address|expression> [length]
+
+Prints length bytes of memory (default 256) starting at the specified address, or at the address of the expression, and shows them in the Memory panel.`},
+		{aliases: []string{"set"}, needsProcess: true, cmdFn: setVar, complete: completeVariable, helpMsg: `Changes the value of a variable.
 
 	set <variable> = <value>
 
@@ -123,12 +227,50 @@ Saves the current layout.
 	
 Lists saved layouts.`},
 		{aliases: []string{"config"}, cmdFn: configCommand, helpMsg: `Configuration`},
+		{aliases: []string{"runconfig"}, cmdFn: runConfigCommand, helpMsg: `Changes how the target is built and started.
+
+Opens a window to edit the arguments, build flags, build tags, working directory and environment of the target, which are used the next time it's restarted. The initial values can be set with the --build-flags, --tags, --wd and --env options of the debug, run, exec and test subcommands.`},
 		{aliases: []string{"scroll"}, cmdFn: scrollCommand, helpMsg: `Controls scrollback behavior.
 	
 	scroll clear		Clears scrollback
 	scroll silence		Silences output from inferior
 	scroll noise		Re-enables output from inferior.
+	scroll save <file>	Saves everything written to the scrollback since gdlv started, including the parts that were truncated.
+	scroll size [<high> <low>]	When the scrollback becomes longer than high characters the first low characters are removed.
+
+The output of the inferior is shown in the Output panel, or in the scrollback if no Output panel is open.
+
+Ctrl+F over the scrollback searches its contents.
 `},
+		{aliases: []string{"source"}, cmdFn: sourceCommand, helpMsg: `Executes a file containing a list of commands.
+
+	source <path>
+
+Commands are executed one per line, stopping at the first command that fails. Empty lines and lines starting with # are ignored.
+
+After connecting to the target $HOME/.config/gdlvinit ($APPDATA/gdlvinit on windows) and .gdlvinit in the current directory are executed automatically, if they exist.`},
+		{aliases: []string{"alias"}, cmdFn: aliasCommand, helpMsg: `Defines a command alias.
+
+	alias <name> <command>[; <command>...]
+
+Executing name executes the list of commands, any argument passed to name is appended to the last command.
+
+	alias -d <name>
+
+Deletes the alias or macro called name.
+
+	alias
+
+Lists all aliases and macros.`},
+		{aliases: []string{"define"}, cmdFn: defineCommand, helpMsg: `Defines a macro.
+
+	define <name> { <command>[; <command>...] }
+
+Executing name executes the list of commands, replacing $1 ... $9 with the corresponding argument passed to name and $* with all the arguments, outside of string literals. For example:
+
+	define pq { print $1.queue; print len($1.queue) }
+
+Macros are deleted with alias -d <name>.`},
 		{aliases: []string{"exit", "quit", "q"}, cmdFn: exitCommand, helpMsg: "Exit the debugger."},
 	}
 
@@ -138,6 +280,12 @@ Lists saved layouts.`},
 
 var noCmdError = errors.New("command not available")
 
+var coreFileError = errors.New("command not available when debugging a core file")
+
+func coreFileCommand(out io.Writer, args string) error {
+	return coreFileError
+}
+
 func noCmdAvailable(out io.Writer, args string) error {
 	return noCmdError
 }
@@ -156,6 +304,10 @@ func (c *Commands) help(out io.Writer, args string) error {
 				}
 			}
 		}
+		if h, ok := userCommandHelp(args); ok {
+			fmt.Fprintln(out, h)
+			return nil
+		}
 		return noCmdError
 	}
 
@@ -176,11 +328,22 @@ func (c *Commands) help(out io.Writer, args string) error {
 	if err := w.Flush(); err != nil {
 		return err
 	}
+	if len(conf.Aliases)+len(conf.Macros) > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "User defined commands:")
+		if err := printUserCommands(out); err != nil {
+			return err
+		}
+	}
 	fmt.Fprintln(out, "Type help followed by a command for full documentation.")
 	fmt.Fprintln(out)
 	fmt.Fprintln(out, "Keybindings:")
 	fmt.Fprintln(w, "    Ctrl +/- \t Zoom in/out")
 	fmt.Fprintln(w, "    Escape \t Focus command line")
+	fmt.Fprintln(w, "    Ctrl R \t Search command history")
+	fmt.Fprintln(w, "    Ctrl F \t Search the panel under the mouse")
+	fmt.Fprintln(w, "    Ctrl Shift F \t Find in sources")
+	fmt.Fprintln(w, "    F12 \t Toggle performance overlay")
 	fmt.Fprintln(w, "    Ctrl delete \t Request manual stop")
 	if err := w.Flush(); err != nil {
 		return err
@@ -190,6 +353,38 @@ func (c *Commands) help(out io.Writer, args string) error {
 
 func setBreakpoint(out io.Writer, tracepoint bool, argstr string) error {
 	defer refreshState(refreshToSameFrame, clearBreakpoint, nil)
+
+	var extra breakpointExtra
+optionsLoop:
+	for {
+		argstr = strings.TrimSpace(argstr)
+		opt, rest := argstr, ""
+		if i := strings.IndexAny(argstr, " \t"); i >= 0 {
+			opt, rest = argstr[:i], strings.TrimSpace(argstr[i+1:])
+		}
+		switch opt {
+		case "-temp":
+			extra.Temporary = true
+			argstr = rest
+		case "-hit":
+			if rest == "" {
+				return fmt.Errorf("hit condition required")
+			}
+			v := strings.SplitN(rest, " ", 2)
+			var err error
+			extra.HitCondOp, extra.HitCondN, err = parseHitCondition(v[0])
+			if err != nil {
+				return err
+			}
+			if len(v) != 2 {
+				return fmt.Errorf("location required")
+			}
+			argstr = v[1]
+		default:
+			break optionsLoop
+		}
+	}
+
 	args := strings.SplitN(argstr, " ", 2)
 
 	requestedBp := &api.Breakpoint{}
@@ -209,7 +404,7 @@ func setBreakpoint(out io.Writer, tracepoint bool, argstr string) error {
 	}
 
 	requestedBp.Tracepoint = tracepoint
-	locs, err := client.FindLocation(api.EvalScope{curGid, curFrame}, locspec)
+	locs, err := client.FindLocation(evalScope(), locspec)
 	if err != nil {
 		if requestedBp.Name == "" {
 			return err
@@ -217,26 +412,36 @@ func setBreakpoint(out io.Writer, tracepoint bool, argstr string) error {
 		requestedBp.Name = ""
 		locspec = argstr
 		var err2 error
-		locs, err2 = client.FindLocation(api.EvalScope{curGid, curFrame}, locspec)
+		locs, err2 = client.FindLocation(evalScope(), locspec)
 		if err2 != nil {
 			return err
 		}
 	}
 	for _, loc := range locs {
 		requestedBp.Addr = loc.PC
-		setBreakpointEx(out, requestedBp)
+		bp := setBreakpointEx(out, requestedBp)
+		if bp != nil && extra != (breakpointExtra{}) {
+			setBreakpointExtra(bp.ID, extra)
+			fmt.Fprintf(out, "    %s\n", extra)
+		}
+	}
+	if extra != (breakpointExtra{}) {
+		saveFrozenBreakpoints()
 	}
 	return nil
 }
 
-func setBreakpointEx(out io.Writer, requestedBp *api.Breakpoint) {
+func setBreakpointEx(out io.Writer, requestedBp *api.Breakpoint) *api.Breakpoint {
 	bp, err := client.CreateBreakpoint(requestedBp)
 	if err != nil {
 		fmt.Fprintf(out, "Could not create breakpoint: %v\n", err)
+		return nil
 	}
 
 	fmt.Fprintf(out, "%s set at %s\n", formatBreakpointName(bp, true), formatBreakpointLocation(bp))
 	freezeBreakpoint(out, bp)
+	saveFrozenBreakpoints()
+	return bp
 }
 
 func breakpoint(out io.Writer, args string) error {
@@ -251,6 +456,7 @@ func clear(out io.Writer, args string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("not enough arguments")
 	}
+	defer refreshState(refreshToSameFrame, clearBreakpoint, nil)
 	id, err := strconv.Atoi(args)
 	var bp *api.Breakpoint
 	if err == nil {
@@ -260,13 +466,150 @@ func clear(out io.Writer, args string) error {
 	}
 	removeFrozenBreakpoint(bp)
 	if err != nil {
-		return err
+		i := findDisabledBreakpoint(args)
+		if i < 0 {
+			return err
+		}
+		bp = &api.Breakpoint{}
+		*bp = FrozenBreakpoints[i].Bp
+		deleteFrozenBreakpoint(i)
 	}
 	fmt.Fprintf(out, "%s cleared at %s\n", formatBreakpointName(bp, true), formatBreakpointLocation(bp))
 	return nil
 }
 
+func enableCommand(out io.Writer, args string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("not enough arguments")
+	}
+	i := findDisabledBreakpoint(args)
+	if i < 0 {
+		return fmt.Errorf("no disabled breakpoint %s", args)
+	}
+	defer refreshState(refreshToSameFrame, clearBreakpoint, nil)
+	bp, err := enableBreakpoint(out, i)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s enabled at %s\n", formatBreakpointName(bp, true), formatBreakpointLocation(bp))
+	return nil
+}
+
+func disableCommand(out io.Writer, args string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("not enough arguments")
+	}
+	bp, err := getBreakpointByIDOrName(args)
+	if err != nil {
+		return err
+	}
+	defer refreshState(refreshToSameFrame, clearBreakpoint, nil)
+	if err := disableBreakpoint(out, bp); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s disabled at %s\n", formatBreakpointName(bp, true), formatBreakpointLocation(bp))
+	return nil
+}
+
+func getBreakpointByIDOrName(arg string) (*api.Breakpoint, error) {
+	if id, err := strconv.Atoi(arg); err == nil {
+		return client.GetBreakpoint(id)
+	}
+	return client.GetBreakpointByName(arg)
+}
+
+func amendBreakpointEx(bp *api.Breakpoint) error {
+	defer refreshState(refreshToSameFrame, clearBreakpoint, nil)
+	if err := client.AmendBreakpoint(bp); err != nil {
+		return err
+	}
+	updateFrozenBreakpoints()
+	saveFrozenBreakpoints()
+	return nil
+}
+
+func onCommand(out io.Writer, args string) error {
+	argv := strings.SplitN(args, " ", 3)
+	if len(argv) < 2 {
+		return fmt.Errorf("not enough arguments")
+	}
+	bp, err := getBreakpointByIDOrName(argv[0])
+	if err != nil {
+		return err
+	}
+
+	cmdarg := ""
+	if len(argv) > 2 {
+		cmdarg = strings.TrimSpace(argv[2])
+	}
+
+	switch argv[1] {
+	case "print", "p":
+		if cmdarg == "" {
+			return fmt.Errorf("not enough arguments")
+		}
+		bp.Variables = append(bp.Variables, cmdarg)
+	case "stack":
+		depth, err := strconv.Atoi(cmdarg)
+		if err != nil {
+			return fmt.Errorf("wrong argument for stack: %v", err)
+		}
+		bp.Stacktrace = depth
+	case "args":
+		bp.LoadArgs = onLoadConfig(cmdarg)
+	case "locals":
+		bp.LoadLocals = onLoadConfig(cmdarg)
+	case "goroutine":
+		bp.Goroutine = true
+	default:
+		return fmt.Errorf("unknown command %q", argv[1])
+	}
+
+	return amendBreakpointEx(bp)
+}
+
+func onLoadConfig(arg string) *api.LoadConfig {
+	if arg == "-v" {
+		return &LongLoadConfig
+	}
+	return &ShortLoadConfig
+}
+
+func condCommand(out io.Writer, args string) error {
+	argv := strings.SplitN(args, " ", 2)
+	if len(argv) < 1 || argv[0] == "" {
+		return fmt.Errorf("not enough arguments")
+	}
+	bp, err := getBreakpointByIDOrName(argv[0])
+	if err != nil {
+		return err
+	}
+	bp.Cond = ""
+	if len(argv) > 1 {
+		bp.Cond = strings.TrimSpace(argv[1])
+	}
+	return amendBreakpointEx(bp)
+}
+
 func restart(out io.Writer, args string) error {
+	if args != "" {
+		if args != "--" && !strings.HasPrefix(args, "-- ") {
+			return errors.New("usage: restart [-- args...]")
+		}
+		if !BackendServer.canConfigure() {
+			return errors.New("arguments can only be changed when gdlv starts the target")
+		}
+		BackendServer.setArgs(splitQuotedFields(args[2:]))
+		BackendServer.updateCommandLine()
+		// delve can not change the arguments of the target
+		BackendServer.restartServer = true
+	}
+
+	if BackendServer.restartServer && BackendServer.serverProcess != nil {
+		BackendServer.restartDelve(out)
+		return nil
+	}
+
 	dorestart := BackendServer.serverProcess != nil
 	BackendServer.Rebuild()
 	if !dorestart || !BackendServer.buildok {
@@ -294,31 +637,126 @@ func restart(out io.Writer, args string) error {
 }
 
 func cont(out io.Writer, args string) error {
-	stateChan := client.Continue()
-	var state *api.DebuggerState
-	for state = range stateChan {
-		if state.Err != nil {
-			return state.Err
-		}
-		printcontext(out, state)
+	state, err := continueWithHitConditions(out)
+	if err != nil {
+		return err
 	}
 	refreshState(refreshToFrameZero, clearStop, state)
 	return nil
 }
 
-func continueUntilCompleteNext(out io.Writer, state *api.DebuggerState, op string) error {
-	if !state.NextInProgress {
-		refreshState(refreshToFrameZero, clearStop, state)
-		return nil
+func runTo(out io.Writer, args string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("not enough arguments")
+	}
+	locs, err := client.FindLocation(evalScope(), args)
+	if err != nil {
+		return err
+	}
+	switch len(locs) {
+	case 1:
+		// ok
+	case 0:
+		return errors.New("no location found")
+	default:
+		return errors.New("can not run to multiple locations")
+	}
+
+	// use the breakpoint already at the target location, if there is one
+	var bp *api.Breakpoint
+	bps, err := client.ListBreakpoints()
+	if err != nil {
+		return err
+	}
+	for _, existing := range bps {
+		if existing.ID >= 0 && existing.Addr == locs[0].PC {
+			bp = existing
+			break
+		}
+	}
+	if bp == nil {
+		// the breakpoint is never frozen, it must not survive the stop
+		bp, err = client.CreateBreakpoint(&api.Breakpoint{Addr: locs[0].PC})
+		if err != nil {
+			return err
+		}
+		defer func() {
+			client.ClearBreakpoint(bp.ID)
+			refreshState(refreshToSameFrame, clearBreakpoint, nil)
+		}()
+	}
+
+	state, err := continueWithHitConditions(out)
+	if err != nil {
+		return err
 	}
+
+	reached := false
+	for _, th := range state.Threads {
+		if th.Breakpoint != nil && th.Breakpoint.ID == bp.ID {
+			reached = true
+			break
+		}
+	}
+	if reached {
+		if state.NextInProgress {
+			client.CancelNext()
+			state.NextInProgress = false
+		}
+	} else if state.CurrentThread != nil && state.CurrentThread.Breakpoint != nil {
+		fmt.Fprintf(out, "%s hit before reaching %s\n", formatBreakpointName(state.CurrentThread.Breakpoint, true), args)
+	}
+
+	refreshState(refreshToFrameZero, clearStop, state)
+	return nil
+}
+
+// Continues execution, resuming it automatically every time the program
+// stops only at breakpoints whose hit condition is not satisfied.
+func continueWithHitConditions(out io.Writer) (*api.DebuggerState, error) {
 	for {
 		stateChan := client.Continue()
 		var state *api.DebuggerState
 		for state = range stateChan {
 			if state.Err != nil {
-				return state.Err
+				return nil, state.Err
+			}
+			if !hitConditionsUnmet(state) {
+				printcontext(out, state)
 			}
-			printcontext(out, state)
+		}
+		if !hitConditionsUnmet(state) {
+			clearTemporaryBreakpoints(out, state)
+			return state, nil
+		}
+	}
+}
+
+// Handles the state returned by a step command like
+// continueWithHitConditions does: if a breakpoint whose hit condition is not
+// satisfied interrupted the step the program is resumed.
+func stepWithHitConditions(out io.Writer, state *api.DebuggerState) (*api.DebuggerState, error) {
+	if hitConditionsUnmet(state) {
+		if state.NextInProgress {
+			return continueWithHitConditions(out)
+		}
+		printcontext(out, state)
+		return state, nil
+	}
+	printcontext(out, state)
+	clearTemporaryBreakpoints(out, state)
+	return state, nil
+}
+
+func continueUntilCompleteNext(out io.Writer, state *api.DebuggerState, op string) error {
+	if !state.NextInProgress {
+		refreshState(refreshToFrameZero, clearStop, state)
+		return nil
+	}
+	for {
+		state, err := continueWithHitConditions(out)
+		if err != nil {
+			return err
 		}
 		if !state.NextInProgress || conf.StopOnNextBreakpoint {
 			refreshState(refreshToFrameZero, clearStop, state)
@@ -333,7 +771,10 @@ func step(out io.Writer, args string) error {
 	if err != nil {
 		return err
 	}
-	printcontext(out, state)
+	state, err = stepWithHitConditions(out, state)
+	if err != nil {
+		return err
+	}
 	return continueUntilCompleteNext(out, state, "step")
 }
 
@@ -342,7 +783,10 @@ func stepInstruction(out io.Writer, args string) error {
 	if err != nil {
 		return err
 	}
-	printcontext(out, state)
+	state, err = stepWithHitConditions(out, state)
+	if err != nil {
+		return err
+	}
 	refreshState(refreshToFrameZero, clearStop, state)
 	return nil
 }
@@ -352,7 +796,10 @@ func next(out io.Writer, args string) error {
 	if err != nil {
 		return err
 	}
-	printcontext(out, state)
+	state, err = stepWithHitConditions(out, state)
+	if err != nil {
+		return err
+	}
 	return continueUntilCompleteNext(out, state, "next")
 }
 
@@ -361,7 +808,10 @@ func stepout(out io.Writer, args string) error {
 	if err != nil {
 		return err
 	}
-	printcontext(out, state)
+	state, err = stepWithHitConditions(out, state)
+	if err != nil {
+		return err
+	}
 	return continueUntilCompleteNext(out, state, "stepout")
 }
 
@@ -382,7 +832,7 @@ func printVar(out io.Writer, args string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("not enough arguments")
 	}
-	val, err := client.EvalVariable(api.EvalScope{curGid, curFrame}, args, LongLoadConfig)
+	val, err := client.EvalVariable(evalScope(), args, LongLoadConfig)
 	if err != nil {
 		return err
 	}
@@ -392,7 +842,7 @@ func printVar(out io.Writer, args string) error {
 }
 
 func listCommand(out io.Writer, args string) error {
-	locs, err := client.FindLocation(api.EvalScope{curGid, curFrame}, args)
+	locs, err := client.FindLocation(evalScope(), args)
 	if err != nil {
 		return err
 	}
@@ -411,6 +861,351 @@ func listCommand(out io.Writer, args string) error {
 	return nil
 }
 
+// commandScope, when it isn't nil, replaces the current goroutine and
+// frame for commands executed with a goroutine or frame prefix.
+var commandScope *api.EvalScope
+
+// evalScope returns the scope in which commands evaluate expressions.
+func evalScope() api.EvalScope {
+	if commandScope != nil {
+		return *commandScope
+	}
+	return api.EvalScope{curGid, curFrame}
+}
+
+// switchFrame makes frame the current frame of the current goroutine.
+func switchFrame(frame int) error {
+	if frame < 0 {
+		return fmt.Errorf("invalid frame %d", frame)
+	}
+	if evalScope().GoroutineID != curGid {
+		return errors.New("can not change the frame of a goroutine other than the current one")
+	}
+	frames, err := client.Stacktrace(curGid, frame, nil)
+	if err != nil {
+		return err
+	}
+	if frame >= len(frames) {
+		return fmt.Errorf("invalid frame %d, the stack has %d frames", frame, len(frames))
+	}
+	mu.Lock()
+	curFrame = frame
+	mu.Unlock()
+	if commandScope != nil {
+		commandScope.Frame = frame
+	}
+	refreshState(refreshToSameFrame, clearFrameSwitch, nil)
+	return nil
+}
+
+// scopedCommand executes cmdstr evaluating expressions in goroutine gid
+// and frame, the current goroutine and frame are not changed.
+func scopedCommand(out io.Writer, gid, frame int, cmdstr string) error {
+	old := commandScope
+	commandScope = &api.EvalScope{gid, frame}
+	defer func() { commandScope = old }()
+	cmdstr, args := parseCommand(cmdstr)
+	return cmds.Call(cmdstr, args, out)
+}
+
+func frameCommand(out io.Writer, args string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("not enough arguments")
+	}
+	v := strings.SplitN(args, " ", 2)
+	frame, err := strconv.Atoi(v[0])
+	if err != nil {
+		return fmt.Errorf("invalid frame %q", v[0])
+	}
+	if len(v) == 1 {
+		return switchFrame(frame)
+	}
+	return scopedCommand(out, evalScope().GoroutineID, frame, strings.TrimSpace(v[1]))
+}
+
+func upDownArgument(args string) (int, error) {
+	if len(args) == 0 {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(args)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid argument %q", args)
+	}
+	return n, nil
+}
+
+func upCommand(out io.Writer, args string) error {
+	n, err := upDownArgument(args)
+	if err != nil {
+		return err
+	}
+	return switchFrame(evalScope().Frame + n)
+}
+
+func downCommand(out io.Writer, args string) error {
+	n, err := upDownArgument(args)
+	if err != nil {
+		return err
+	}
+	return switchFrame(evalScope().Frame - n)
+}
+
+func goroutineCommand(out io.Writer, args string) error {
+	if len(args) == 0 {
+		gid := evalScope().GoroutineID
+		if gid < 0 {
+			fmt.Fprintln(out, "No current goroutine")
+			return nil
+		}
+		gs, err := client.ListGoroutines()
+		if err != nil {
+			return err
+		}
+		var g *api.Goroutine
+		for i := range gs {
+			if gs[i].ID == gid {
+				g = gs[i]
+				break
+			}
+		}
+		if g == nil {
+			return fmt.Errorf("goroutine %d not found", gid)
+		}
+		writeGoroutineLong(out, g, "")
+		return nil
+	}
+
+	v := strings.SplitN(args, " ", 2)
+	gid, err := strconv.Atoi(v[0])
+	if err != nil {
+		return fmt.Errorf("invalid goroutine id %q", v[0])
+	}
+	if len(v) > 1 {
+		return scopedCommand(out, gid, 0, strings.TrimSpace(v[1]))
+	}
+
+	state, err := client.SwitchGoroutine(gid)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Switched from %d to %d\n", curGid, gid)
+	if commandScope != nil {
+		*commandScope = api.EvalScope{gid, 0}
+	}
+	refreshState(refreshToFrameZero, clearGoroutineSwitch, state)
+	return nil
+}
+
+func threadCommand(out io.Writer, args string) error {
+	if len(args) == 0 {
+		if curThread < 0 {
+			fmt.Fprintln(out, "No current thread")
+			return nil
+		}
+		fmt.Fprintf(out, "Thread %d\n", curThread)
+		return nil
+	}
+
+	tid, err := strconv.Atoi(args)
+	if err != nil {
+		return fmt.Errorf("invalid thread id %q", args)
+	}
+	state, err := client.SwitchThread(tid)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Switched from %d to %d\n", curThread, tid)
+	refreshState(refreshToFrameZero, clearGoroutineSwitch, state)
+	return nil
+}
+
+func goroutinesCommand(out io.Writer, args string) error {
+	location := goroutineLocations[goroutinesPanel.goroutineLocation]
+	switch args {
+	case "":
+		// use the location selected in the goroutines panel
+	case "-u":
+		location = userGoroutineLocation
+	case "-r":
+		location = currentGoroutineLocation
+	case "-g":
+		location = goStatementLocation
+	default:
+		return fmt.Errorf("unknown option %q", args)
+	}
+
+	gs, err := client.ListGoroutines()
+	if err != nil {
+		return err
+	}
+	sort.Sort(goroutinesByID(gs))
+	fmt.Fprintf(out, "[%d goroutines]\n", len(gs))
+	for _, g := range gs {
+		prefix := "  "
+		if g.ID == evalScope().GoroutineID {
+			prefix = "* "
+		}
+		var loc api.Location
+		switch location {
+		case currentGoroutineLocation:
+			loc = g.CurrentLoc
+		case userGoroutineLocation:
+			loc = g.UserCurrentLoc
+		case goStatementLocation:
+			loc = g.GoStatementLoc
+		}
+		thread := ""
+		if g.ThreadID != 0 {
+			thread = fmt.Sprintf(" (thread %d)", g.ThreadID)
+		}
+		fmt.Fprintf(out, "%sGoroutine %d - %s: %s%s\n", prefix, g.ID, location, formatLocation(loc), thread)
+	}
+	return nil
+}
+
+func stackCommand(out io.Writer, args string) error {
+	depth := stackPanel.depth
+	var cfg *api.LoadConfig
+	for _, arg := range strings.Fields(args) {
+		if arg == "-full" {
+			cfg = &ShortLoadConfig
+			continue
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid depth %q", arg)
+		}
+		depth = n
+	}
+	stack, err := client.Stacktrace(evalScope().GoroutineID, depth, cfg)
+	if err != nil {
+		return err
+	}
+	printStack(out, stack, "")
+	return nil
+}
+
+// parseVariablesArgs parses the arguments of the locals and args commands
+func parseVariablesArgs(args string) (verbose bool, cfg api.LoadConfig, filter string) {
+	if args == "-v" || strings.HasPrefix(args, "-v ") {
+		return true, LongLoadConfig, strings.TrimSpace(args[2:])
+	}
+	return false, ShortLoadConfig, args
+}
+
+func printVariables(out io.Writer, vars []api.Variable, verbose bool, filter string) {
+	sort.Sort(variablesByName(vars))
+	n := 0
+	for _, v := range vars {
+		if strings.Index(v.Name, filter) < 0 {
+			continue
+		}
+		n++
+		if verbose {
+			fmt.Fprintf(out, "%s = %s\n", v.Name, v.MultilineString(""))
+		} else {
+			fmt.Fprintf(out, "%s = %s\n", v.Name, v.SinglelineString())
+		}
+	}
+	if n == 0 {
+		fmt.Fprintln(out, "(no variables)")
+	}
+}
+
+func localsCommand(out io.Writer, args string) error {
+	verbose, cfg, filter := parseVariablesArgs(args)
+	vars, err := client.ListLocalVariables(evalScope(), cfg)
+	if err != nil {
+		return err
+	}
+	printVariables(out, vars, verbose, filter)
+	return nil
+}
+
+func argsCommand(out io.Writer, args string) error {
+	verbose, cfg, filter := parseVariablesArgs(args)
+	vars, err := client.ListFunctionArgs(evalScope(), cfg)
+	if err != nil {
+		return err
+	}
+	printVariables(out, vars, verbose, filter)
+	return nil
+}
+
+func regsCommand(out io.Writer, args string) error {
+	var all bool
+	switch args {
+	case "":
+		// only general purpose registers
+	case "-a":
+		all = true
+	default:
+		return fmt.Errorf("unknown option %q", args)
+	}
+	// thread 0 is the current thread
+	threadID := 0
+	if gid := evalScope().GoroutineID; gid != curGid {
+		gs, err := client.ListGoroutines()
+		if err != nil {
+			return err
+		}
+		threadID = -1
+		for _, g := range gs {
+			if g.ID == gid {
+				threadID = g.ThreadID
+				break
+			}
+		}
+		switch threadID {
+		case -1:
+			return fmt.Errorf("goroutine %d not found", gid)
+		case 0:
+			return fmt.Errorf("goroutine %d is not running on a thread", gid)
+		}
+	}
+	regs, err := client.ListRegisters(threadID, all)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, regs.String())
+	return nil
+}
+
+func breakpointsCommand(out io.Writer, args string) error {
+	bps, err := client.ListBreakpoints()
+	if err != nil {
+		return err
+	}
+	sort.Sort(breakpointsByID(bps))
+	printbp := func(bp *api.Breakpoint, disabled bool) {
+		state := ""
+		if disabled {
+			state = " (disabled)"
+		}
+		fmt.Fprintf(out, "%s%s at %s (%d)\n", formatBreakpointName(bp, true), state, formatBreakpointLocation(bp), bp.TotalHitCount)
+		if bp.Cond != "" {
+			fmt.Fprintf(out, "\tcond %s\n", bp.Cond)
+		}
+		if extra := getBreakpointExtra(bp.ID); !disabled && extra != (breakpointExtra{}) {
+			fmt.Fprintf(out, "\t%s\n", extra)
+		}
+		for _, v := range bp.Variables {
+			fmt.Fprintf(out, "\tprint %s\n", v)
+		}
+	}
+	for _, bp := range bps {
+		if bp.ID < 0 {
+			// internal breakpoints are not interesting
+			continue
+		}
+		printbp(bp, false)
+	}
+	for _, bp := range disabledBreakpoints() {
+		printbp(bp, true)
+	}
+	return nil
+}
+
 func setVar(out io.Writer, args string) error {
 	// HACK: in go '=' is not an operator, we detect the error and try to recover from it by splitting the input string
 	_, err := parser.ParseExpr(args)
@@ -425,7 +1220,7 @@ func setVar(out io.Writer, args string) error {
 
 	lexpr := args[:el[0].Pos.Offset]
 	rexpr := args[el[0].Pos.Offset+1:]
-	return client.SetVariable(api.EvalScope{curGid, curFrame}, lexpr, rexpr)
+	return client.SetVariable(evalScope(), lexpr, rexpr)
 }
 
 // ExitRequestError is returned when the user
@@ -542,7 +1337,18 @@ func configWindow(w *nucular.Window) {
 }
 
 func scrollCommand(out io.Writer, args string) error {
-	switch args {
+	cmd, rest := parseCommand(args)
+	switch cmd {
+	case "save":
+		if rest == "" {
+			return errors.New("not enough arguments")
+		}
+		if err := saveScrollbackLog(rest); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Scrollback saved to %s\n", rest)
+	case "size":
+		return scrollSizeCommand(out, rest)
 	case "clear":
 		mu.Lock()
 		scrollbackEditor.Buffer = scrollbackEditor.Buffer[:0]
@@ -760,12 +1566,7 @@ func executeCommand(cmdstr string) {
 	cmdstr, args := parseCommand(cmdstr)
 	if err := cmds.Call(cmdstr, args, &out); err != nil {
 		if _, ok := err.(ExitRequestError); ok {
-			if client.AttachedToExistingProcess() {
-				wnd.PopupOpen("Confirm Quit", dynamicPopupFlags, rect.Rect{100, 100, 400, 700}, true, confirmQuit)
-			} else {
-				client.Detach(true)
-				wnd.Close()
-			}
+			exitRequested()
 		}
 		// The type information gets lost in serialization / de-serialization,
 		// so we do a string compare on the error message to see if the process
@@ -778,6 +1579,17 @@ func executeCommand(cmdstr string) {
 	}
 }
 
+// exitRequested closes gdlv after the quit command was executed, asking
+// whether the target should be killed if gdlv attached to it.
+func exitRequested() {
+	if client.AttachedToExistingProcess() {
+		wnd.PopupOpen("Confirm Quit", dynamicPopupFlags, rect.Rect{100, 100, 400, 700}, true, confirmQuit)
+	} else {
+		client.Detach(true)
+		wnd.Close()
+	}
+}
+
 func confirmQuit(w *nucular.Window) {
 	w.Row(20).Dynamic(1)
 	w.Label("Would you like to kill the process?", "LT")
@@ -809,7 +1621,8 @@ func parseCommand(cmdstr string) (string, string) {
 }
 
 // Find will look up the command function for the given command input.
-// If it cannot find the command it will default to noCmdAvailable().
+// If it cannot find the command, or a user defined command with that name,
+// it will default to noCmdAvailable().
 // If the command is an empty string it will replay the last command.
 func (c *Commands) Find(cmdstr string) cmdfunc {
 	// If <enter> use last command, if there was one.
@@ -822,11 +1635,19 @@ func (c *Commands) Find(cmdstr string) cmdfunc {
 
 	for _, v := range c.cmds {
 		if v.match(cmdstr) {
+			if v.needsProcess && BackendServer.core {
+				return coreFileCommand
+			}
 			c.lastCmd = v.cmdFn
 			return v.cmdFn
 		}
 	}
 
+	if fn := findUserCommand(cmdstr); fn != nil {
+		c.lastCmd = fn
+		return fn
+	}
+
 	return noCmdAvailable
 }
 
@@ -5,6 +5,7 @@ package main
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -127,6 +128,73 @@ func completeVariable() {
 	cm.finish()
 }
 
+func completeBreakpoint() {
+	word := lastWord([]rune{' '})
+	cm := completeMachine{word: word}
+
+	breakpointsPanel.asyncLoad.mu.Lock()
+	defer breakpointsPanel.asyncLoad.mu.Unlock()
+
+	if !breakpointsPanel.asyncLoad.loaded {
+		return
+	}
+
+	for _, bp := range breakpointsPanel.breakpoints {
+		if bp.ID < 0 {
+			continue
+		}
+		if bp.Name != "" {
+			cm.add(bp.Name)
+		}
+		cm.add(strconv.Itoa(bp.ID))
+	}
+
+	cm.finish()
+}
+
+func completeDisabledBreakpoint() {
+	word := lastWord([]rune{' '})
+	cm := completeMachine{word: word}
+	for _, bp := range disabledBreakpoints() {
+		if bp.Name != "" {
+			cm.add(bp.Name)
+		}
+		cm.add(strconv.Itoa(bp.ID))
+	}
+	cm.finish()
+}
+
+var onCommands = []string{"print", "stack", "args", "locals", "goroutine"}
+
+func completeOn() {
+	switch argumentIndex() {
+	case 0:
+		completeBreakpoint()
+	case 1:
+		completeWord(lastWord([]rune{' '}), onCommands)
+	default:
+		completeVariable()
+	}
+}
+
+func completeCond() {
+	if argumentIndex() == 0 {
+		completeBreakpoint()
+	} else {
+		completeVariable()
+	}
+}
+
+// Returns the index of the command argument under the cursor
+func argumentIndex() int {
+	buf := commandLineEditor.Buffer[:commandLineEditor.Cursor]
+	fields := strings.Fields(string(buf))
+	if len(buf) > 0 && buf[len(buf)-1] == ' ' {
+		return len(fields) - 1
+	}
+	return len(fields) - 2
+}
+
 func completeCommand() {
 	if cmds == nil || len(commandLineEditor.Buffer) == 0 {
 		return
@@ -138,6 +206,9 @@ func completeCommand() {
 			cm.add(alias)
 		}
 	}
+	for _, name := range userCommandNames() {
+		cm.add(name)
+	}
 	cm.finish()
 }
 
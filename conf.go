@@ -14,6 +14,11 @@ type Configuration struct {
 	StopOnNextBreakpoint bool
 	DisassemblyFlavour   int
 	Layouts              map[string]LayoutDescr
+	Aliases              map[string]string
+	Macros               map[string]string
+	ScrollbackHighMark   int
+	ScrollbackLowMark    int
+	AutoRestart          bool
 }
 
 type LayoutDescr struct {
@@ -33,6 +38,18 @@ func adjustConfiguration() {
 		conf.Layouts["sl"] = LayoutDescr{"|300_250LC_180Sl", "Stacktrace and Locals"}
 		conf.Layouts["tr"] = LayoutDescr{"|300_250LC_180Tl", "Threads and Registers"}
 	}
+	if conf.ScrollbackHighMark < minScrollbackHighMark {
+		conf.ScrollbackHighMark = defaultScrollbackHighMark
+	}
+	if conf.ScrollbackLowMark <= 0 || conf.ScrollbackLowMark >= conf.ScrollbackHighMark {
+		conf.ScrollbackLowMark = conf.ScrollbackHighMark / 2
+	}
+	if conf.Aliases == nil {
+		conf.Aliases = map[string]string{}
+	}
+	if conf.Macros == nil {
+		conf.Macros = map[string]string{}
+	}
 	if ld, ok := conf.Layouts["default"]; !ok || ld.Layout == "" {
 		conf.Layouts["default"] = LayoutDescr{"|300_250LC_180Sl", "Default layout"}
 	}
@@ -46,6 +63,14 @@ func configLoc() string {
 	return os.ExpandEnv(loc)
 }
 
+func breakpointsLoc() string {
+	return configLoc() + "-breakpoints"
+}
+
+func historyLoc() string {
+	return configLoc() + "-history"
+}
+
 func loadConfiguration() {
 	defer adjustConfiguration()
 	fh, err := os.Open(configLoc())
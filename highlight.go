@@ -0,0 +1,228 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"bytes"
+	"go/scanner"
+	"go/token"
+	"image/color"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/aarzilli/nucular"
+	"github.com/aarzilli/nucular/rect"
+	nstyle "github.com/aarzilli/nucular/style"
+)
+
+type tokenKind uint8
+
+const (
+	tokenPlain tokenKind = iota
+	tokenKeyword
+	tokenString
+	tokenComment
+	tokenNumber
+	tokenPredeclared
+	numTokenKinds
+)
+
+var darkHighlightColors = [numTokenKinds]color.RGBA{
+	tokenKeyword:     {0xf9, 0x26, 0x72, 0xff},
+	tokenString:      {0xe6, 0xdb, 0x74, 0xff},
+	tokenComment:     {0x75, 0x71, 0x5e, 0xff},
+	tokenNumber:      {0xae, 0x81, 0xff, 0xff},
+	tokenPredeclared: {0x66, 0xd9, 0xef, 0xff},
+}
+
+var whiteHighlightColors = [numTokenKinds]color.RGBA{
+	tokenKeyword:     {0x00, 0x00, 0xa0, 0xff},
+	tokenString:      {0xa3, 0x15, 0x15, 0xff},
+	tokenComment:     {0x00, 0x80, 0x00, 0xff},
+	tokenNumber:      {0x09, 0x86, 0x58, 0xff},
+	tokenPredeclared: {0x26, 0x7f, 0x99, 0xff},
+}
+
+var predeclaredIdents = map[string]bool{
+	"bool": true, "byte": true, "complex64": true, "complex128": true,
+	"error": true, "float32": true, "float64": true, "int": true,
+	"int8": true, "int16": true, "int32": true, "int64": true,
+	"rune": true, "string": true, "uint": true, "uint8": true,
+	"uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"true": true, "false": true, "iota": true, "nil": true,
+	"append": true, "cap": true, "close": true, "complex": true,
+	"copy": true, "delete": true, "imag": true, "len": true,
+	"make": true, "new": true, "panic": true, "print": true,
+	"println": true, "real": true, "recover": true,
+}
+
+// textSegment is a piece of a line of source code, with tabs expanded,
+// drawn with the colour of kind.
+type textSegment struct {
+	text string
+	kind tokenKind
+}
+
+type highlightedFile struct {
+	modTime time.Time
+	lines   [][]textSegment
+}
+
+// highlightCache maps file names to their highlighted contents, protected
+// by mu.
+var highlightCache = map[string]*highlightedFile{}
+
+// highlightFile returns the highlighted lines of path, which was last
+// modified at modTime. Returns nil if path isn't a Go source file or can
+// not be read.
+func highlightFile(path string, modTime time.Time) [][]textSegment {
+	if !strings.HasSuffix(path, ".go") {
+		return nil
+	}
+	if hf := highlightCache[path]; hf != nil && hf.modTime.Equal(modTime) {
+		return hf.lines
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	lines := highlightSource(src)
+	highlightCache[path] = &highlightedFile{modTime, lines}
+	return lines
+}
+
+func highlightSource(src []byte) [][]textSegment {
+	kinds := make([]tokenKind, len(src))
+
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		start := file.Offset(pos)
+		end := start + len(lit)
+		var kind tokenKind
+		switch {
+		case tok == token.COMMENT:
+			// the scanner removes carriage returns from the text of
+			// comments, find the end in the source instead
+			kind = tokenComment
+			if bytes.HasPrefix(src[start:], []byte("/*")) {
+				end = findEnd(src, start+2, "*/")
+			} else {
+				end = findEnd(src, start, "\n")
+			}
+		case tok == token.STRING || tok == token.CHAR:
+			kind = tokenString
+			if src[start] == '`' {
+				end = findEnd(src, start+1, "`")
+			}
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			kind = tokenNumber
+		case tok.IsKeyword():
+			kind = tokenKeyword
+			end = start + len(tok.String())
+		case tok == token.IDENT && predeclaredIdents[lit]:
+			kind = tokenPredeclared
+		default:
+			continue
+		}
+		if end > len(src) {
+			end = len(src)
+		}
+		for i := start; i < end; i++ {
+			kinds[i] = kind
+		}
+	}
+
+	lines := [][]textSegment{}
+	for start := 0; start < len(src); {
+		end := bytes.IndexByte(src[start:], '\n')
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += start
+		}
+		lineEnd := end
+		if lineEnd > start && src[lineEnd-1] == '\r' {
+			lineEnd--
+		}
+		lines = append(lines, lineSegments(src[start:lineEnd], kinds[start:lineEnd]))
+		start = end + 1
+	}
+	return lines
+}
+
+// findEnd returns the offset after the first occurrence of delim in src
+// after start, or len(src).
+func findEnd(src []byte, start int, delim string) int {
+	if start > len(src) {
+		return len(src)
+	}
+	i := bytes.Index(src[start:], []byte(delim))
+	if i < 0 {
+		return len(src)
+	}
+	return start + i + len(delim)
+}
+
+// lineSegments splits a line into segments of the same kind, expanding
+// tabs the same way expandTabs does.
+func lineSegments(line []byte, kinds []tokenKind) []textSegment {
+	var segments []textSegment
+	var buf bytes.Buffer
+	count := 0
+	for i := 0; i < len(line); {
+		kind := kinds[i]
+		buf.Reset()
+		for i < len(line) && kinds[i] == kind {
+			switch line[i] {
+			case '\t':
+				d := (((count / 8) + 1) * 8) - count
+				for j := 0; j < d; j++ {
+					buf.WriteByte(' ')
+				}
+				count = 0
+			default:
+				buf.WriteByte(line[i])
+				if line[i] < 0x80 || line[i] >= 0xc0 {
+					count++
+				}
+			}
+			i++
+		}
+		segments = append(segments, textSegment{buf.String(), kind})
+	}
+	return segments
+}
+
+// highlightedLabel draws a line of highlighted source code as a single
+// widget.
+func highlightedLabel(w *nucular.Window, segments []textSegment) {
+	bounds, out := w.Custom(nstyle.WidgetStateInactive)
+	if out == nil {
+		return
+	}
+	style := w.Master().Style()
+	colors := &darkHighlightColors
+	if conf.WhiteTheme {
+		colors = &whiteHighlightColors
+	}
+	fh := nucular.FontHeight(style.Font)
+	r := rect.Rect{X: bounds.X + style.Text.Padding.X, Y: bounds.Y + bounds.H/2 - fh/2, H: 2 * fh}
+	for _, seg := range segments {
+		r.W = nucular.FontWidth(style.Font, seg.text)
+		c := colors[seg.kind]
+		if seg.kind == tokenPlain {
+			c = style.Text.Color
+		}
+		out.DrawText(r, seg.text, style.Font, c)
+		r.X += r.W
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aarzilli/nucular"
+
+	"golang.org/x/mobile/event/key"
+)
+
+// maxHistory is the maximum number of commands saved in the history file.
+const maxHistory = 1000
+
+func loadHistory() {
+	fh, err := os.Open(historyLoc())
+	if err != nil {
+		return
+	}
+	defer fh.Close()
+	scan := bufio.NewScanner(fh)
+	for scan.Scan() {
+		if scan.Text() != "" {
+			cmdhistory = append(cmdhistory, scan.Text())
+		}
+	}
+	historyShown = len(cmdhistory)
+}
+
+func saveHistory() {
+	fh, err := os.Create(historyLoc())
+	if err != nil {
+		return
+	}
+	defer fh.Close()
+	w := bufio.NewWriter(fh)
+	for _, cmd := range cmdhistory[1:] {
+		fmt.Fprintln(w, cmd)
+	}
+	w.Flush()
+}
+
+// addHistory appends cmd to the command history, removing older copies of
+// it, and saves the history.
+func addHistory(cmd string) {
+	h := cmdhistory[:1]
+	for _, old := range cmdhistory[1:] {
+		if old != cmd {
+			h = append(h, old)
+		}
+	}
+	h = append(h, cmd)
+	if len(h)-1 > maxHistory {
+		h = append(h[:1], h[len(h)-maxHistory:]...)
+	}
+	cmdhistory = h
+	saveHistory()
+}
+
+// historySearch is the state of the reverse incremental search of the
+// command history, started with Ctrl+R.
+var historySearch struct {
+	active bool
+	query  string
+	idx    int
+	failed bool
+	saved  []rune
+}
+
+func historySearchPrompt() string {
+	if historySearch.failed {
+		return fmt.Sprintf("(failed reverse-i-search)`%s':", historySearch.query)
+	}
+	return fmt.Sprintf("(reverse-i-search)`%s':", historySearch.query)
+}
+
+// searchHistory shows the most recent command, starting at index from,
+// containing the search query.
+func searchHistory(from int) {
+	if from >= len(cmdhistory) {
+		from = len(cmdhistory) - 1
+	}
+	for i := from; i > 0; i-- {
+		if j := strings.Index(cmdhistory[i], historySearch.query); j >= 0 {
+			historySearch.idx = i
+			historySearch.failed = false
+			commandLineEditor.Buffer = []rune(cmdhistory[i])
+			commandLineEditor.Cursor = len([]rune(cmdhistory[i][:j]))
+			commandLineEditor.CursorFollow = true
+			return
+		}
+	}
+	historySearch.failed = true
+}
+
+func stopHistorySearch(accept bool) {
+	historySearch.active = false
+	if !accept {
+		commandLineEditor.Buffer = historySearch.saved
+	}
+	commandLineEditor.Cursor = len(commandLineEditor.Buffer)
+	commandLineEditor.CursorFollow = true
+}
+
+// historySearchKeys handles the keyboard input of the command line while
+// searching the history, keys that end the search are left in the input
+// to be processed normally.
+func historySearchKeys(w *nucular.Window) {
+	kbd := &w.Input().Keyboard
+	if !historySearch.active {
+		for i, k := range kbd.Keys {
+			if k.Modifiers == key.ModControl && k.Code == key.CodeR {
+				historySearch.active = true
+				historySearch.query = ""
+				historySearch.idx = len(cmdhistory)
+				historySearch.failed = false
+				historySearch.saved = append([]rune{}, commandLineEditor.Buffer...)
+				kbd.Keys = kbd.Keys[i+1:]
+				kbd.Text = ""
+				break
+			}
+		}
+		if !historySearch.active {
+			return
+		}
+	}
+
+	if kbd.Text != "" {
+		historySearch.query += kbd.Text
+		searchHistory(historySearch.idx)
+		kbd.Text = ""
+	}
+
+	for i, k := range kbd.Keys {
+		switch {
+		case k.Modifiers == key.ModControl && k.Code == key.CodeR:
+			searchHistory(historySearch.idx - 1)
+		case k.Modifiers == 0 && k.Code == key.CodeDeleteBackspace:
+			if q := []rune(historySearch.query); len(q) > 0 {
+				historySearch.query = string(q[:len(q)-1])
+				searchHistory(len(cmdhistory) - 1)
+			}
+		case (k.Modifiers == 0 && k.Code == key.CodeEscape) || (k.Modifiers == key.ModControl && k.Code == key.CodeG):
+			stopHistorySearch(false)
+			kbd.Keys = kbd.Keys[:0]
+			return
+		case (k.Modifiers == 0 || k.Modifiers == key.ModShift) && k.Rune >= ' ':
+			// already added to the query
+		default:
+			stopHistorySearch(true)
+			kbd.Keys = kbd.Keys[i:]
+			return
+		}
+	}
+	kbd.Keys = kbd.Keys[:0]
+}
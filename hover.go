@@ -0,0 +1,116 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"go/token"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/aarzilli/nucular"
+	"github.com/derekparker/delve/service/api"
+)
+
+// Expressions hovered in the listing panel are evaluated in the background
+// and their values are kept until the current scope changes.
+
+type hoverValue struct {
+	v       *api.Variable
+	err     error
+	loading bool
+}
+
+var hoverEval = struct {
+	mu    sync.Mutex
+	cache map[string]*hoverValue
+}{
+	cache: map[string]*hoverValue{},
+}
+
+func clearHoverEval() {
+	hoverEval.mu.Lock()
+	hoverEval.cache = map[string]*hoverValue{}
+	hoverEval.mu.Unlock()
+}
+
+func isIdentRune(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch)
+}
+
+// exprAtColumn returns the identifier or selector expression at column col
+// of line, stopping at the end of the identifier under the cursor.
+// Returns an empty string if there is no expression at col.
+func exprAtColumn(line *listline, col int) string {
+	text := []rune(line.text)
+	if col < 0 || col >= len(text) || !isIdentRune(text[col]) {
+		return ""
+	}
+
+	if line.segments != nil {
+		segcol := 0
+		for _, seg := range line.segments {
+			n := len([]rune(seg.text))
+			if col < segcol+n {
+				if seg.kind != tokenPlain && seg.kind != tokenPredeclared {
+					return ""
+				}
+				break
+			}
+			segcol += n
+		}
+	}
+
+	end := col
+	for end < len(text) && isIdentRune(text[end]) {
+		end++
+	}
+	start := col
+	for {
+		for start > 0 && isIdentRune(text[start-1]) {
+			start--
+		}
+		if start < 2 || text[start-1] != '.' || !isIdentRune(text[start-2]) {
+			break
+		}
+		start--
+	}
+
+	expr := string(text[start:end])
+	for _, part := range strings.Split(expr, ".") {
+		if part == "" || unicode.IsDigit([]rune(part)[0]) || token.Lookup(part).IsKeyword() {
+			return ""
+		}
+	}
+	return expr
+}
+
+// hoverEvalTooltip shows the value of expr in a tooltip, evaluating it if
+// it isn't cached.
+func hoverEvalTooltip(w *nucular.Window, expr string) {
+	hoverEval.mu.Lock()
+	hv := hoverEval.cache[expr]
+	if hv == nil {
+		hv = &hoverValue{loading: true}
+		hoverEval.cache[expr] = hv
+		go func() {
+			v, err := client.EvalVariable(evalScope(), expr, ShortLoadConfig)
+			hoverEval.mu.Lock()
+			hv.v, hv.err, hv.loading = v, err, false
+			hoverEval.mu.Unlock()
+			wnd.Changed()
+		}()
+	}
+	var tooltip string
+	switch {
+	case hv.loading:
+		tooltip = expr + " = loading..."
+	case hv.err != nil:
+		tooltip = expr + ": " + hv.err.Error()
+	default:
+		tooltip = expr + " = " + variableValueString(hv.v)
+	}
+	hoverEval.mu.Unlock()
+
+	w.Tooltip(tooltip)
+}
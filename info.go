@@ -23,6 +23,8 @@ import (
 	"github.com/aarzilli/nucular/rect"
 
 	"github.com/derekparker/delve/service/api"
+
+	"golang.org/x/mobile/event/mouse"
 )
 
 type asyncLoad struct {
@@ -95,8 +97,22 @@ var goroutinesPanel = struct {
 	asyncLoad         asyncLoad
 	goroutineLocation int
 	goroutines        []*api.Goroutine
+	buckets           []goroutineBucket
+	grouped           bool
+	filterEditor      nucular.TextEditor
+	filtered          []*api.Goroutine
+	vl                virtualList
 }{
 	goroutineLocation: 1,
+	filterEditor:      nucular.TextEditor{Filter: spacefilter},
+}
+
+// Goroutines with the same user location and go statement location
+type goroutineBucket struct {
+	key        string
+	user       api.Location
+	gostmt     api.Location
+	goroutines []*api.Goroutine
 }
 
 var stackPanel = struct {
@@ -146,22 +162,32 @@ var globalsPanel = struct {
 	filterEditor nucular.TextEditor
 	showAddr     bool
 	globals      []api.Variable
+	filtered     []int
+	vl           virtualList
 }{
 	filterEditor: nucular.TextEditor{Filter: spacefilter},
 }
 
 var breakpointsPanel = struct {
-	asyncLoad   asyncLoad
-	selected    int
-	breakpoints []*api.Breakpoint
+	asyncLoad asyncLoad
+	selected  int
+	// disabled breakpoints keep the ID they had when they were disabled,
+	// which can be the same as the ID of an enabled breakpoint
+	selectedDisabled bool
+	breakpoints      []*api.Breakpoint
+	disabled         []*api.Breakpoint
 }{}
 
+var disabledColor = color.RGBA{0x80, 0x80, 0x80, 0xff}
+
 type stringSlicePanel struct {
 	name         string
 	filterEditor nucular.TextEditor
 	slice        []string
 	selected     int
 	interaction  func(p *stringSlicePanel, w *nucular.Window, clicked bool, idx int)
+	filtered     []int
+	vl           virtualList
 }
 
 var funcsPanel = stringSlicePanel{name: "functions", selected: -1, interaction: funcInteraction}
@@ -182,15 +208,65 @@ func (gs goroutinesByID) Swap(i, j int) {
 }
 func (gs goroutinesByID) Less(i, j int) bool { return gs[i].ID < gs[j].ID }
 
+type bucketsBySize []goroutineBucket
+
+func (bs bucketsBySize) Len() int      { return len(bs) }
+func (bs bucketsBySize) Swap(i, j int) { bs[i], bs[j] = bs[j], bs[i] }
+func (bs bucketsBySize) Less(i, j int) bool {
+	if len(bs[i].goroutines) != len(bs[j].goroutines) {
+		return len(bs[i].goroutines) > len(bs[j].goroutines)
+	}
+	return bs[i].key < bs[j].key
+}
+
 func loadGoroutines(p *asyncLoad) {
 	var err error
 	goroutinesPanel.goroutines, err = client.ListGoroutines()
 	if err == nil {
 		sort.Sort(goroutinesByID(goroutinesPanel.goroutines))
 	}
+	goroutinesPanel.buckets = bucketGoroutines(goroutinesPanel.goroutines)
 	p.done(err)
 }
 
+func bucketGoroutines(goroutines []*api.Goroutine) []goroutineBucket {
+	idx := map[string]int{}
+	var buckets []goroutineBucket
+	for _, g := range goroutines {
+		key := fmt.Sprintf("%s:%d %s:%d", g.UserCurrentLoc.File, g.UserCurrentLoc.Line, g.GoStatementLoc.File, g.GoStatementLoc.Line)
+		i, ok := idx[key]
+		if !ok {
+			i = len(buckets)
+			idx[key] = i
+			buckets = append(buckets, goroutineBucket{key: key, user: g.UserCurrentLoc, gostmt: g.GoStatementLoc})
+		}
+		buckets[i].goroutines = append(buckets[i].goroutines, g)
+	}
+	sort.Sort(bucketsBySize(buckets))
+	return buckets
+}
+
+func locationMatches(loc api.Location, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	if loc.Function != nil && strings.Index(loc.Function.Name, filter) >= 0 {
+		return true
+	}
+	return strings.Index(loc.File, filter) >= 0
+}
+
+func goroutineDisplayLocation(g *api.Goroutine) api.Location {
+	switch goroutineLocations[goroutinesPanel.goroutineLocation] {
+	case currentGoroutineLocation:
+		return g.CurrentLoc
+	case goStatementLocation:
+		return g.GoStatementLoc
+	default:
+		return g.UserCurrentLoc
+	}
+}
+
 func updateGoroutines(container *nucular.Window) {
 	w := goroutinesPanel.asyncLoad.showRequest(container, nucular.WindowNoHScrollbar, "goroutines", loadGoroutines)
 	if w == nil {
@@ -202,10 +278,15 @@ func updateGoroutines(container *nucular.Window) {
 	goroutines := goroutinesPanel.goroutines
 
 	w.MenubarBegin()
-	w.Row(20).Static(180)
+	w.Row(20).Static(180, 80, 60, 0)
 	goroutinesPanel.goroutineLocation = w.ComboSimple(goroutineLocations, goroutinesPanel.goroutineLocation, 22)
+	w.CheckboxText("Group", &goroutinesPanel.grouped)
+	w.Label("Filter:", "LC")
+	goroutinesPanel.filterEditor.Edit(w)
 	w.MenubarEnd()
 
+	filter := string(goroutinesPanel.filterEditor.Buffer)
+
 	pad := style.Selectable.Padding.X * 2
 	d := 1
 	if len(goroutines) > 0 {
@@ -223,41 +304,69 @@ func updateGoroutines(container *nucular.Window) {
 
 	zerow := nucular.FontWidth(style.Font, "0")
 
-	w.Row(40).StaticScaled(zerow*d+pad, zerow*dthread+pad, 0)
-	for _, g := range goroutines {
-		selected := curGid == g.ID
-		w.SelectableLabel(fmt.Sprintf("%*d", d, g.ID), "LT", &selected)
-		if g.ThreadID != 0 {
-			w.SelectableLabel(fmt.Sprintf("%*d", dthread, g.ThreadID), "LT", &selected)
-		} else {
-			w.SelectableLabel(" ", "LT", &selected)
-		}
-		switch goroutineLocations[goroutinesPanel.goroutineLocation] {
-		case currentGoroutineLocation:
-			w.SelectableLabel(formatLocation2(g.CurrentLoc), "LT", &selected)
-		case userGoroutineLocation:
-			w.SelectableLabel(formatLocation2(g.UserCurrentLoc), "LT", &selected)
-		case goStatementLocation:
-			w.SelectableLabel(formatLocation2(g.GoStatementLoc), "LT", &selected)
-		}
-		if selected && curGid != g.ID && !running {
-			go func(gid int) {
-				state, err := client.SwitchGoroutine(gid)
-				if err != nil {
-					out := editorWriter{&scrollbackEditor, true}
-					fmt.Fprintf(&out, "Could not switch goroutine: %v\n", err)
-				} else {
-					refreshto := refreshToFrameZero
-					if goroutineLocations[goroutinesPanel.goroutineLocation] == userGoroutineLocation {
-						refreshto = refreshToUserFrame
-					}
-					go refreshState(refreshto, clearGoroutineSwitch, state)
-				}
-			}(g.ID)
+	if !goroutinesPanel.grouped {
+		refreshto := refreshToFrameZero
+		if goroutineLocations[goroutinesPanel.goroutineLocation] == userGoroutineLocation {
+			refreshto = refreshToUserFrame
+		}
+		goroutinesPanel.filtered = goroutinesPanel.filtered[:0]
+		for _, g := range goroutines {
+			if locationMatches(goroutineDisplayLocation(g), filter) {
+				goroutinesPanel.filtered = append(goroutinesPanel.filtered, g)
+			}
+		}
+		goroutinesPanel.vl.update(w, len(goroutinesPanel.filtered), 40, func(i int) {
+			w.Row(40).StaticScaled(zerow*d+pad, zerow*dthread+pad, 0)
+			goroutineRow(w, goroutinesPanel.filtered[i], d, dthread, refreshto)
+		})
+		return
+	}
+
+	for _, bucket := range goroutinesPanel.buckets {
+		if !locationMatches(bucket.user, filter) && !locationMatches(bucket.gostmt, filter) {
+			continue
+		}
+		w.Row(varRowHeight).Dynamic(1)
+		userfn, gofn := "(nil)", "(nil)"
+		if bucket.user.Function != nil {
+			userfn = bucket.user.Function.Name
+		}
+		if bucket.gostmt.Function != nil {
+			gofn = bucket.gostmt.Function.Name
+		}
+		title := fmt.Sprintf("[%d] %s at %s:%d, created by %s at %s:%d", len(bucket.goroutines), userfn, ShortenFilePath(bucket.user.File), bucket.user.Line, gofn, ShortenFilePath(bucket.gostmt.File), bucket.gostmt.Line)
+		if w.TreePushNamed(nucular.TreeNode, bucket.key, title, false) {
+			w.Row(40).StaticScaled(zerow*d+pad, zerow*dthread+pad, 0)
+			for _, g := range bucket.goroutines {
+				goroutineRow(w, g, d, dthread, refreshToUserFrame)
+			}
+			w.TreePop()
 		}
 	}
 }
 
+func goroutineRow(w *nucular.Window, g *api.Goroutine, d, dthread int, refreshto refreshToFrame) {
+	selected := curGid == g.ID
+	w.SelectableLabel(fmt.Sprintf("%*d", d, g.ID), "LT", &selected)
+	if g.ThreadID != 0 {
+		w.SelectableLabel(fmt.Sprintf("%*d", dthread, g.ThreadID), "LT", &selected)
+	} else {
+		w.SelectableLabel(" ", "LT", &selected)
+	}
+	w.SelectableLabel(formatLocation2(goroutineDisplayLocation(g)), "LT", &selected)
+	if selected && curGid != g.ID && !running {
+		go func(gid int) {
+			state, err := client.SwitchGoroutine(gid)
+			if err != nil {
+				out := editorWriter{&scrollbackEditor, true}
+				fmt.Fprintf(&out, "Could not switch goroutine: %v\n", err)
+			} else {
+				go refreshState(refreshto, clearGoroutineSwitch, state)
+			}
+		}(g.ID)
+	}
+}
+
 func loadStacktrace(p *asyncLoad) {
 	var err error
 	stackPanel.stack, err = client.Stacktrace(curGid, stackPanel.depth, nil)
@@ -404,6 +513,15 @@ func loadLocals(p *asyncLoad) {
 	for i := range localsPanel.locals {
 		changename(&localsPanel.locals[i])
 	}
+
+	scope := currentVariablesScope(localVariables)
+	resetChangedMarks(localVariables)
+	for i := range localsPanel.args {
+		compareToSnapshot(scope, localsPanel.args[i].Name, &localsPanel.args[i])
+	}
+	for i := range localsPanel.locals {
+		compareToSnapshot(scope, localsPanel.locals[i].Name, &localsPanel.locals[i])
+	}
 	if errarg != nil {
 		p.done(errarg)
 		return
@@ -451,17 +569,20 @@ func updateLocals(container *nucular.Window) {
 	}
 }
 
-func loadOneExpr(i int) {
+func loadOneExpr(i int, scope variablesScope) {
 	var err error
 	exprsPanel.v[i], err = client.EvalVariable(api.EvalScope{curGid, curFrame}, exprsPanel.expressions[i], LongLoadConfig)
 	if err != nil {
 		exprsPanel.v[i] = &api.Variable{Name: exprsPanel.expressions[i], Unreadable: err.Error()}
 	}
+	compareToSnapshot(scope, exprsPanel.expressions[i], exprsPanel.v[i])
 }
 
 func loadExprs(l *asyncLoad) {
+	resetChangedMarks(exprVariables)
+	scope := currentVariablesScope(exprVariables)
 	for i := range exprsPanel.expressions {
-		loadOneExpr(i)
+		loadOneExpr(i, scope)
 	}
 	l.done(nil)
 }
@@ -508,26 +629,33 @@ func exprsEditor(w *nucular.Window) {
 	exprsPanel.ed.CursorFollow = true
 
 	if exprsPanel.selected < 0 {
-		exprsPanel.expressions = append(exprsPanel.expressions, newexpr)
-		exprsPanel.v = append(exprsPanel.v, nil)
-		i := len(exprsPanel.v) - 1
-		go func(i int) {
-			additionalLoadMu.Lock()
-			defer additionalLoadMu.Unlock()
-			loadOneExpr(i)
-		}(i)
+		addExpression(newexpr)
 	} else {
 		exprsPanel.expressions[exprsPanel.selected] = newexpr
 		go func(i int) {
 			additionalLoadMu.Lock()
 			defer additionalLoadMu.Unlock()
-			loadOneExpr(i)
+			loadOneExpr(i, currentVariablesScope(exprVariables))
 		}(exprsPanel.selected)
 		exprsPanel.selected = -1
 	}
 }
 
+func addExpression(newexpr string) {
+	exprsPanel.expressions = append(exprsPanel.expressions, newexpr)
+	exprsPanel.v = append(exprsPanel.v, nil)
+	i := len(exprsPanel.v) - 1
+	go func(i int) {
+		additionalLoadMu.Lock()
+		defer additionalLoadMu.Unlock()
+		loadOneExpr(i, currentVariablesScope(exprVariables))
+	}(i)
+}
+
 func showExprMenu(w *nucular.Window, exprMenuIdx int, v *api.Variable) {
+	if old := changedVariable(v); old != nil && w.Input().Mouse.HoveringRect(w.LastWidgetBounds) {
+		w.Tooltip("previous value: " + variableValueString(old))
+	}
 	if running {
 		return
 	}
@@ -606,6 +734,11 @@ func loadGlobals(p *asyncLoad) {
 	var err error
 	globalsPanel.globals, err = client.ListPackageVariables("", LongLoadConfig)
 	sort.Sort(variablesByName(globalsPanel.globals))
+	scope := currentVariablesScope(globalVariables)
+	resetChangedMarks(globalVariables)
+	for i := range globalsPanel.globals {
+		compareToSnapshot(scope, globalsPanel.globals[i].Name, &globalsPanel.globals[i])
+	}
 	p.done(err)
 }
 
@@ -626,11 +759,29 @@ func updateGlobals(container *nucular.Window) {
 
 	globals := globalsPanel.globals
 
+	// rows can only be virtualized while all variables are collapsed
+	anyOpen := false
+	globalsPanel.filtered = globalsPanel.filtered[:0]
 	for i := range globals {
 		if strings.Index(globals[i].Name, filter) >= 0 {
+			globalsPanel.filtered = append(globalsPanel.filtered, i)
+			if w.TreeIsOpen(globals[i].Name) {
+				anyOpen = true
+			}
+		}
+	}
+
+	if anyOpen {
+		for _, i := range globalsPanel.filtered {
 			showVariable(w, 0, globalsPanel.showAddr, -1, globals[i].Name, &globals[i])
 		}
+		return
 	}
+
+	globalsPanel.vl.update(w, len(globalsPanel.filtered), varRowHeight, func(j int) {
+		i := globalsPanel.filtered[j]
+		showVariable(w, 0, globalsPanel.showAddr, -1, globals[i].Name, &globals[i])
+	})
 }
 
 type breakpointsByID []*api.Breakpoint
@@ -641,7 +792,7 @@ func (bps breakpointsByID) Swap(i, j int) {
 	bps[i] = bps[j]
 	bps[j] = temp
 }
-func (bps breakpointsByID) Less(i, j int) bool { return bps[i].ID < bps[i].ID }
+func (bps breakpointsByID) Less(i, j int) bool { return bps[i].ID < bps[j].ID }
 
 func loadBreakpoints(p *asyncLoad) {
 	var err error
@@ -649,6 +800,7 @@ func loadBreakpoints(p *asyncLoad) {
 	if err == nil {
 		sort.Sort(breakpointsByID(breakpointsPanel.breakpoints))
 	}
+	breakpointsPanel.disabled = disabledBreakpoints()
 	p.done(err)
 }
 
@@ -668,65 +820,112 @@ func updateBreakpoints(container *nucular.Window) {
 	if len(breakpoints) > 0 {
 		d = digits(breakpoints[len(breakpoints)-1].ID)
 	}
+	for _, bp := range breakpointsPanel.disabled {
+		if dd := digits(bp.ID); dd > d {
+			d = dd
+		}
+	}
 	if d < 3 {
 		d = 3
 	}
 
 	w.Row(40).StaticScaled(zeroWidth*d+pad, 0)
 	for _, breakpoint := range breakpoints {
-		oldselectedId := breakpointsPanel.selected
-		selected := breakpointsPanel.selected == breakpoint.ID
-		w.SelectableLabel(fmt.Sprintf("%*d", d, breakpoint.ID), "LT", &selected)
-		bounds := w.LastWidgetBounds
-		bounds.W = w.Bounds.W
-		w.SelectableLabel(fmt.Sprintf("%s in %s\nat %s:%d (%#v)", breakpoint.Name, breakpoint.FunctionName, breakpoint.File, breakpoint.Line, breakpoint.Addr), "LT", &selected)
-		if !running {
-			if selected {
-				breakpointsPanel.selected = breakpoint.ID
-			}
+		breakpointsPanelRow(w, d, breakpoint, false)
+	}
 
-			if w := w.ContextualOpen(0, image.Point{}, bounds, nil); w != nil {
-				breakpointsPanel.selected = breakpoint.ID
-				w.Row(20).Dynamic(1)
-				if w.MenuItem(label.TA("Edit...", "LC")) {
-					if bp := breakpointsPanelSelectedBreakpoint(); bp != nil {
-						openBreakpointEditor(w.Master(), bp)
-					}
-				}
-				if w.MenuItem(label.TA("Clear", "LC")) {
-					go execClearBreakpoint(breakpointsPanel.selected)
-				}
-				if w.MenuItem(label.TA("Clear All", "LC")) {
-					go func() {
-						scrollbackOut := editorWriter{&scrollbackEditor, true}
-						for _, bp := range breakpointsPanel.breakpoints {
-							if bp.ID < 0 {
-								continue
-							}
-							_, err := client.ClearBreakpoint(bp.ID)
-							if err != nil {
-								fmt.Fprintf(&scrollbackOut, "Could not clear breakpoint %d: %v\n", bp.ID, err)
-							}
-						}
-						FrozenBreakpoints = nil
-						refreshState(refreshToSameFrame, clearBreakpoint, nil)
-						wnd.Changed()
-					}()
-				}
-			}
+	if len(breakpointsPanel.disabled) > 0 {
+		oldstyle := style.Selectable
+		style.Selectable.TextNormal = disabledColor
+		style.Selectable.TextHover = disabledColor
+		style.Selectable.TextPressed = disabledColor
+		style.Selectable.TextNormalActive = disabledColor
+		style.Selectable.TextHoverActive = disabledColor
+		style.Selectable.TextPressedActive = disabledColor
+		for _, breakpoint := range breakpointsPanel.disabled {
+			breakpointsPanelRow(w, d, breakpoint, true)
+		}
+		style.Selectable = oldstyle
+	}
+}
+
+func breakpointsPanelRow(w *nucular.Window, d int, breakpoint *api.Breakpoint, disabled bool) {
+	oldselectedId, oldselectedDisabled := breakpointsPanel.selected, breakpointsPanel.selectedDisabled
+	selected := breakpointsPanel.selected == breakpoint.ID && breakpointsPanel.selectedDisabled == disabled
+	w.SelectableLabel(fmt.Sprintf("%*d", d, breakpoint.ID), "LT", &selected)
+	bounds := w.LastWidgetBounds
+	bounds.W = w.Bounds.W
+	desc := fmt.Sprintf("%s in %s\nat %s:%d (%#v)", breakpoint.Name, breakpoint.FunctionName, breakpoint.File, breakpoint.Line, breakpoint.Addr)
+	if extra := getBreakpointExtra(breakpoint.ID); !disabled && extra != (breakpointExtra{}) {
+		desc = fmt.Sprintf("%s [%s]", desc, extra)
+	}
+	w.SelectableLabel(desc, "LT", &selected)
+	if running {
+		return
+	}
+
+	if selected {
+		breakpointsPanel.selected, breakpointsPanel.selectedDisabled = breakpoint.ID, disabled
+	}
 
-			if breakpointsPanel.selected != oldselectedId {
+	if w := w.ContextualOpen(0, image.Point{}, bounds, nil); w != nil {
+		breakpointsPanel.selected, breakpointsPanel.selectedDisabled = breakpoint.ID, disabled
+		w.Row(20).Dynamic(1)
+		if disabled {
+			if w.MenuItem(label.TA("Enable", "LC")) {
+				go execEnableBreakpoint(breakpoint.ID)
+			}
+		} else {
+			if w.MenuItem(label.TA("Edit...", "LC")) {
 				if bp := breakpointsPanelSelectedBreakpoint(); bp != nil {
-					listingPanel.pinnedLoc = &api.Location{File: bp.File, Line: bp.Line, PC: bp.Addr}
-					go refreshState(refreshToSameFrame, clearNothing, nil)
+					openBreakpointEditor(w.Master(), bp)
 				}
 			}
+			if w.MenuItem(label.TA("Disable", "LC")) {
+				go execDisableBreakpoint(breakpoint.ID)
+			}
+		}
+		if w.MenuItem(label.TA("Clear", "LC")) {
+			go execClearBreakpoint(breakpoint.ID, disabled)
+		}
+		if w.MenuItem(label.TA("Clear All", "LC")) {
+			go func() {
+				scrollbackOut := editorWriter{&scrollbackEditor, true}
+				for _, bp := range breakpointsPanel.breakpoints {
+					if bp.ID < 0 {
+						continue
+					}
+					_, err := client.ClearBreakpoint(bp.ID)
+					if err != nil {
+						fmt.Fprintf(&scrollbackOut, "Could not clear breakpoint %d: %v\n", bp.ID, err)
+					}
+				}
+				FrozenBreakpoints = nil
+				saveFrozenBreakpoints()
+				refreshState(refreshToSameFrame, clearBreakpoint, nil)
+				wnd.Changed()
+			}()
+		}
+	}
+
+	if breakpointsPanel.selected != oldselectedId || breakpointsPanel.selectedDisabled != oldselectedDisabled {
+		if bp := breakpointsPanelSelectedBreakpoint(); bp != nil {
+			listingPanel.pinnedLoc = &api.Location{File: bp.File, Line: bp.Line, PC: bp.Addr}
+			go refreshState(refreshToSameFrame, clearNothing, nil)
 		}
 	}
 }
 
 func breakpointsPanelSelectedBreakpoint() *api.Breakpoint {
-	for _, bp := range breakpointsPanel.breakpoints {
+	if !breakpointsPanel.selectedDisabled {
+		for _, bp := range breakpointsPanel.breakpoints {
+			if bp.ID == breakpointsPanel.selected {
+				return bp
+			}
+		}
+		return nil
+	}
+	for _, bp := range breakpointsPanel.disabled {
 		if bp.ID == breakpointsPanel.selected {
 			return bp
 		}
@@ -734,7 +933,15 @@ func breakpointsPanelSelectedBreakpoint() *api.Breakpoint {
 	return nil
 }
 
-func execClearBreakpoint(id int) {
+func execClearBreakpoint(id int, disabled bool) {
+	if disabled {
+		if i := findDisabledBreakpoint(strconv.Itoa(id)); i >= 0 {
+			deleteFrozenBreakpoint(i)
+		}
+		refreshState(refreshToSameFrame, clearBreakpoint, nil)
+		wnd.Changed()
+		return
+	}
 	scrollbackOut := editorWriter{&scrollbackEditor, true}
 	bp, err := client.ClearBreakpoint(id)
 	if err != nil {
@@ -745,8 +952,33 @@ func execClearBreakpoint(id int) {
 	wnd.Changed()
 }
 
+func execEnableBreakpoint(id int) {
+	scrollbackOut := editorWriter{&scrollbackEditor, true}
+	if i := findDisabledBreakpoint(strconv.Itoa(id)); i >= 0 {
+		if _, err := enableBreakpoint(&scrollbackOut, i); err != nil {
+			fmt.Fprintf(&scrollbackOut, "Could not enable breakpoint %d: %v\n", id, err)
+		}
+	}
+	refreshState(refreshToSameFrame, clearBreakpoint, nil)
+	wnd.Changed()
+}
+
+func execDisableBreakpoint(id int) {
+	scrollbackOut := editorWriter{&scrollbackEditor, true}
+	bp, err := client.GetBreakpoint(id)
+	if err == nil {
+		err = disableBreakpoint(&scrollbackOut, bp)
+	}
+	if err != nil {
+		fmt.Fprintf(&scrollbackOut, "Could not disable breakpoint %d: %v\n", id, err)
+	}
+	refreshState(refreshToSameFrame, clearBreakpoint, nil)
+	wnd.Changed()
+}
+
 type breakpointEditor struct {
 	bp          *api.Breakpoint
+	extra       breakpointExtra
 	printEditor nucular.TextEditor
 	condEditor  nucular.TextEditor
 }
@@ -754,6 +986,7 @@ type breakpointEditor struct {
 func openBreakpointEditor(mw nucular.MasterWindow, bp *api.Breakpoint) {
 	var ed breakpointEditor
 	ed.bp = bp
+	ed.extra = getBreakpointExtra(bp.ID)
 
 	ed.printEditor.Flags = nucular.EditMultiline | nucular.EditClipboard | nucular.EditSelectable
 	for i := range bp.Variables {
@@ -827,6 +1060,19 @@ func (bped *breakpointEditor) update(w *nucular.Window) {
 	w.Label("Condition:", "LC")
 	bped.condEditor.Edit(w)
 
+	w.Row(20).Static(70, 60, 150, 100)
+	w.Label("Hit count:", "LC")
+	bped.extra.HitCondOp = hitCondOp(w.ComboSimple(hitCondOps, int(bped.extra.HitCondOp), 20))
+	if bped.extra.HitCondOp != hitCondNone {
+		if bped.extra.HitCondN < 1 {
+			bped.extra.HitCondN = 1
+		}
+		w.PropertyInt("N:", 1, &bped.extra.HitCondN, math.MaxInt32, 1, 1)
+	} else {
+		w.Spacing(1)
+	}
+	w.CheckboxText("Temporary", &bped.extra.Temporary)
+
 	w.Row(20).Static(0, 80, 80)
 	w.Spacing(1)
 	if w.ButtonText("Cancel") {
@@ -848,12 +1094,19 @@ func (bped *breakpointEditor) update(w *nucular.Window) {
 }
 
 func (bped *breakpointEditor) amendBreakpoint() {
-	err := client.AmendBreakpoint(bped.bp)
+	scrollbackOut := editorWriter{&scrollbackEditor, true}
+	if bped.extra.HitCondOp == hitCondNone {
+		bped.extra.HitCondN = 0
+	}
+	if err := checkHitCondition(bped.extra.HitCondOp, bped.extra.HitCondN); err != nil {
+		fmt.Fprintf(&scrollbackOut, "Could not amend breakpoint: wrong hit condition: %v\n", err)
+		return
+	}
+	setBreakpointExtra(bped.bp.ID, bped.extra)
+	err := amendBreakpointEx(bped.bp)
 	if err != nil {
-		scrollbackOut := editorWriter{&scrollbackEditor, true}
 		fmt.Fprintf(&scrollbackOut, "Could not amend breakpoint: %v\n", err)
 	}
-	refreshState(refreshToSameFrame, clearBreakpoint, nil)
 }
 
 func (p *stringSlicePanel) update(container *nucular.Window) {
@@ -874,20 +1127,25 @@ func (p *stringSlicePanel) update(container *nucular.Window) {
 
 	filter := string(p.filterEditor.Buffer)
 
-	w.Row(20).Dynamic(1)
+	p.filtered = p.filtered[:0]
 	for i, value := range p.slice {
-		if strings.Index(value, filter) < 0 {
-			continue
+		if strings.Index(value, filter) >= 0 {
+			p.filtered = append(p.filtered, i)
 		}
+	}
+
+	p.vl.update(w, len(p.filtered), 20, func(j int) {
+		i := p.filtered[j]
+		w.Row(20).Dynamic(1)
 		selected := i == p.selected
-		clicked := w.SelectableLabel(value, "LC", &selected)
+		clicked := w.SelectableLabel(p.slice[i], "LC", &selected)
 		if selected {
 			p.selected = i
 		}
 		if p.interaction != nil {
 			p.interaction(p, w, clicked, i)
 		}
-	}
+	})
 }
 
 func funcInteraction(p *stringSlicePanel, w *nucular.Window, clicked bool, idx int) {
@@ -900,7 +1158,7 @@ func funcInteraction(p *stringSlicePanel, w *nucular.Window, clicked bool, idx i
 	}
 	if w := w.ContextualOpen(0, image.Point{}, w.LastWidgetBounds, nil); w != nil {
 		w.Row(20).Dynamic(1)
-		if w.MenuItem(label.TA("Set breakpoint", "LC")) {
+		if !BackendServer.core && w.MenuItem(label.TA("Set breakpoint", "LC")) {
 			go functionListSetBreakpoint(p.slice[idx])
 		}
 		if w.MenuItem(label.TA("Copy to clipboard", "LC")) {
@@ -963,14 +1221,35 @@ func showVariable(w *nucular.Window, depth int, addr bool, exprMenu int, name st
 		}
 	}
 	w.Row(varRowHeight).StaticScaled(84 * zeroWidth)
+
+	changed := changedVariable(v) != nil
+	headerLabel := func(str string) {
+		if changed {
+			w.LabelColored(str, "LC", changedColor)
+		} else {
+			w.Label(str, "LC")
+		}
+	}
+	treePush := func(title string) bool {
+		if !changed {
+			return w.TreePushNamed(nucular.TreeNode, varname, title, false)
+		}
+		style := w.Master().Style()
+		saved := style.Tab.Text
+		style.Tab.Text = changedColor
+		r := w.TreePushNamed(nucular.TreeNode, varname, title, false)
+		style.Tab.Text = saved
+		return r
+	}
+
 	if v.Unreadable != "" {
-		w.Label(fmt.Sprintf("%s = (unreadable %s)", name, v.Unreadable), "LC")
+		headerLabel(fmt.Sprintf("%s = (unreadable %s)", name, v.Unreadable))
 		showExprMenu(w, exprMenu, v)
 		return
 	}
 
 	if depth > 0 && v.Addr == 0 {
-		w.Label(fmt.Sprintf("%s = nil", name, v.Type), "LC")
+		headerLabel(fmt.Sprintf("%s = nil", name))
 		showExprMenu(w, exprMenu, v)
 		return
 	}
@@ -980,7 +1259,7 @@ func showVariable(w *nucular.Window, depth int, addr bool, exprMenu int, name st
 		if !w.TreeIsOpen(varname) {
 			name += " = " + v.SinglelineString()
 		}
-		if w.TreePushNamed(nucular.TreeNode, varname, name, false) {
+		if treePush(name) {
 			showExprMenu(w, exprMenu, v)
 			w.Label(fmt.Sprintf("len: %d cap: %d", v.Len, v.Cap), "LC")
 			showArrayOrSliceContents(w, depth, addr, v)
@@ -992,7 +1271,7 @@ func showVariable(w *nucular.Window, depth int, addr bool, exprMenu int, name st
 		if !w.TreeIsOpen(varname) {
 			name += " = " + v.SinglelineString()
 		}
-		if w.TreePushNamed(nucular.TreeNode, varname, name, false) {
+		if treePush(name) {
 			showExprMenu(w, exprMenu, v)
 			w.Label(fmt.Sprintf("len: %d", v.Len), "LC")
 			showArrayOrSliceContents(w, depth, addr, v)
@@ -1002,16 +1281,16 @@ func showVariable(w *nucular.Window, depth int, addr bool, exprMenu int, name st
 		}
 	case reflect.Ptr:
 		if v.Type == "" || v.Children[0].Addr == 0 {
-			w.Label(fmt.Sprintf("%s = nil", name), "LC")
+			headerLabel(fmt.Sprintf("%s = nil", name))
 			showExprMenu(w, exprMenu, v)
 		} else if v.Children[0].OnlyAddr && v.Children[0].Addr != 0 {
-			w.Label(fmt.Sprintf("%s = (%s)(%#x)", name, v.Type, v.Children[0].Addr), "LC")
+			headerLabel(fmt.Sprintf("%s = (%s)(%#x)", name, v.Type, v.Children[0].Addr))
 			showExprMenu(w, exprMenu, v)
 		} else {
 			if !w.TreeIsOpen(varname) {
 				name += " = " + v.SinglelineString()
 			}
-			if w.TreePushNamed(nucular.TreeNode, varname, name, false) {
+			if treePush(name) {
 				showExprMenu(w, exprMenu, v)
 				showVariable(w, depth+1, addr, -1, "", &v.Children[0])
 				w.TreePop()
@@ -1020,24 +1299,24 @@ func showVariable(w *nucular.Window, depth int, addr bool, exprMenu int, name st
 			}
 		}
 	case reflect.UnsafePointer:
-		w.Label(fmt.Sprintf("%s = unsafe.Pointer(%#x)", name, v.Children[0].Addr), "LC")
+		headerLabel(fmt.Sprintf("%s = unsafe.Pointer(%#x)", name, v.Children[0].Addr))
 		showExprMenu(w, exprMenu, v)
 	case reflect.String:
 		if v.Len == int64(len(v.Value)) {
-			w.Label(fmt.Sprintf("%s = %q", name, v.Value), "LC")
+			headerLabel(fmt.Sprintf("%s = %q", name, v.Value))
 		} else {
-			w.Label(fmt.Sprintf("%s = %q...", name, v.Value), "LC")
+			headerLabel(fmt.Sprintf("%s = %q...", name, v.Value))
 		}
 		showExprMenu(w, exprMenu, v)
 	case reflect.Chan:
 		if len(v.Children) == 0 {
-			w.Label(fmt.Sprintf("%s = nil", name), "LC")
+			headerLabel(fmt.Sprintf("%s = nil", name))
 			showExprMenu(w, exprMenu, v)
 		} else {
 			if !w.TreeIsOpen(varname) {
 				name += " = " + v.SinglelineString()
 			}
-			if w.TreePushNamed(nucular.TreeNode, varname, name, false) {
+			if treePush(name) {
 				showExprMenu(w, exprMenu, v)
 				showStructContents(w, depth, addr, v)
 				w.TreePop()
@@ -1049,7 +1328,7 @@ func showVariable(w *nucular.Window, depth int, addr bool, exprMenu int, name st
 		if !w.TreeIsOpen(varname) {
 			name += " = " + v.SinglelineString()
 		}
-		if w.TreePushNamed(nucular.TreeNode, varname, name, false) {
+		if treePush(name) {
 			showExprMenu(w, exprMenu, v)
 			if int(v.Len) != len(v.Children) && len(v.Children) == 0 {
 				loadMoreStruct(v)
@@ -1063,13 +1342,13 @@ func showVariable(w *nucular.Window, depth int, addr bool, exprMenu int, name st
 		}
 	case reflect.Interface:
 		if v.Children[0].Kind == reflect.Invalid {
-			w.Label(fmt.Sprintf("%s = nil", name), "LC")
+			headerLabel(fmt.Sprintf("%s = nil", name))
 			showExprMenu(w, exprMenu, v)
 		} else {
 			if !w.TreeIsOpen(varname) {
 				name += " = " + v.SinglelineString()
 			}
-			if w.TreePushNamed(nucular.TreeNode, varname, name, false) {
+			if treePush(name) {
 				showExprMenu(w, exprMenu, v)
 				if v.Children[0].Kind == reflect.Ptr {
 					showVariable(w, depth+1, addr, -1, "data", &v.Children[0].Children[0])
@@ -1085,7 +1364,7 @@ func showVariable(w *nucular.Window, depth int, addr bool, exprMenu int, name st
 		if !w.TreeIsOpen(varname) {
 			name += " = " + v.SinglelineString()
 		}
-		if w.TreePushNamed(nucular.TreeNode, varname, name, false) {
+		if treePush(name) {
 			showExprMenu(w, exprMenu, v)
 			for i := 0; i < len(v.Children); i += 2 {
 				key, value := &v.Children[i], &v.Children[i+1]
@@ -1114,13 +1393,13 @@ func showVariable(w *nucular.Window, depth int, addr bool, exprMenu int, name st
 		}
 	case reflect.Func:
 		if v.Value == "" {
-			w.Label(fmt.Sprintf("%s = nil", name), "LC")
+			headerLabel(fmt.Sprintf("%s = nil", name))
 		} else {
-			w.Label(fmt.Sprintf("%s = %s", name, v.Value), "LC")
+			headerLabel(fmt.Sprintf("%s = %s", name, v.Value))
 		}
 		showExprMenu(w, exprMenu, v)
 	case reflect.Complex64, reflect.Complex128:
-		w.Label(fmt.Sprintf("%s = (%s + %si)", name, v.Children[0].Value, v.Children[1].Value), "LC")
+		headerLabel(fmt.Sprintf("%s = (%s + %si)", name, v.Children[0].Value, v.Children[1].Value))
 		showExprMenu(w, exprMenu, v)
 	default:
 		if v.Value != "" {
@@ -1129,9 +1408,9 @@ func showVariable(w *nucular.Window, depth int, addr bool, exprMenu int, name st
 				v.Value = fmt.Sprintf("%s %q", v.Value, n)
 			}
 
-			w.Label(fmt.Sprintf("%s = %s", name, v.Value), "LC")
+			headerLabel(fmt.Sprintf("%s = %s", name, v.Value))
 		} else {
-			w.Label(fmt.Sprintf("%s = (unknown %s)", name, v.Kind), "LC")
+			headerLabel(fmt.Sprintf("%s = (unknown %s)", name, v.Kind))
 		}
 		showExprMenu(w, exprMenu, v)
 	}
@@ -1265,6 +1544,9 @@ func updateListingPanel(container *nucular.Window) {
 	}
 	defer listp.GroupEnd()
 
+	listingSearchKeys(listp)
+	listingSearchBar(listp)
+
 	style := container.Master().Style()
 
 	arroww := arrowWidth + style.Text.Padding.X*2
@@ -1275,9 +1557,8 @@ func updateListingPanel(container *nucular.Window) {
 		idxw += nucular.FontWidth(style.Font, listingPanel.listing[len(listingPanel.listing)-1].idx)
 	}
 
-	scrollbary := listp.Scrollbar.Y
-
-	for _, line := range listingPanel.listing {
+	listingPanel.vl.update(listp, len(listingPanel.listing), lineheight, func(i int) {
+		line := &listingPanel.listing[i]
 		listp.Row(lineheight).StaticScaled(starw, arroww, idxw, 0)
 
 		rowbounds := listp.WidgetBounds()
@@ -1291,23 +1572,17 @@ func updateListingPanel(container *nucular.Window) {
 		}
 
 		if line.bp != nil {
+			icon := breakpointIcon
+			if line.bpdisabled {
+				icon = disabledBreakpointIcon
+			}
 			iconFace, style.Font = style.Font, iconFace
-			listp.LabelColored(breakpointIcon, "CC", color.RGBA{0xff, 0x00, 0x00, 0xff})
+			listp.LabelColored(icon, "CC", color.RGBA{0xff, 0x00, 0x00, 0xff})
 			iconFace, style.Font = style.Font, iconFace
 		} else {
 			listp.Spacing(1)
 		}
 
-		if centerline && listingPanel.recenterListing {
-			listingPanel.recenterListing = false
-			if above, below := listp.Invisible(); above || below {
-				scrollbary = listp.At().Y - listp.Bounds.H/2
-				if scrollbary < 0 {
-					scrollbary = 0
-				}
-			}
-		}
-
 		if line.pc && curFrame == 0 {
 			iconFace, style.Font = style.Font, iconFace
 			listp.LabelColored(arrowIcon, "CC", color.RGBA{0xff, 0xff, 0x00, 0xff})
@@ -1316,33 +1591,104 @@ func updateListingPanel(container *nucular.Window) {
 			listp.Spacing(1)
 		}
 		listp.Label(line.idx, "LC")
-		listp.Label(line.text, "LC")
+		drawListingMatches(listp, i, listp.WidgetBounds())
+		if line.segments != nil {
+			highlightedLabel(listp, line.segments)
+		} else {
+			listp.Label(line.text, "LC")
+		}
 
 		if !running {
+			textbounds := listp.LastWidgetBounds
+			in := listp.Input()
+			rightClicked := in.Mouse.Clicked(mouse.ButtonRight, rowbounds)
+			if rightClicked {
+				// clicks outside of the text must not reuse the previous expression
+				listingPanel.menuExpr = ""
+			}
+			if in.Mouse.HoveringRect(textbounds) {
+				col := (in.Mouse.Pos.X - textbounds.X - style.Text.Padding.X) / zeroWidth
+				expr := exprAtColumn(line, col)
+				if expr != "" {
+					hoverEvalTooltip(listp, expr)
+				}
+				if rightClicked {
+					listingPanel.menuExpr = expr
+				}
+			}
+
 			if w := listp.ContextualOpen(0, image.Point{}, rowbounds, nil); w != nil {
 				w.Row(20).Dynamic(1)
-				if line.bp != nil {
+				if listingPanel.menuExpr != "" {
+					if w.MenuItem(label.TA("Add to expressions", "LC")) {
+						addExpression(listingPanel.menuExpr)
+					}
+				}
+				switch {
+				case BackendServer.core:
+					// breakpoints can not be set on core files
+				case line.bp != nil && line.bpdisabled:
+					if w.MenuItem(label.TA("Enable breakpoint", "LC")) {
+						go execEnableBreakpoint(line.bp.ID)
+					}
+					if w.MenuItem(label.TA("Clear breakpoint", "LC")) {
+						go execClearBreakpoint(line.bp.ID, true)
+					}
+				case line.bp != nil:
 					if w.MenuItem(label.TA("Edit breakpoint", "LC")) {
 						openBreakpointEditor(w.Master(), line.bp)
 					}
+					if w.MenuItem(label.TA("Disable breakpoint", "LC")) {
+						go execDisableBreakpoint(line.bp.ID)
+					}
 					if w.MenuItem(label.TA("Clear breakpoint", "LC")) {
-						go execClearBreakpoint(line.bp.ID)
+						go execClearBreakpoint(line.bp.ID, false)
 					}
-				} else {
+				default:
 					if w.MenuItem(label.TA("Set breakpoint", "LC")) {
 						go listingSetBreakpoint(listingPanel.file, line.lineno)
 					}
+					if w.MenuItem(label.TA("Run to here", "LC")) {
+						execRunTo(fmt.Sprintf("%s:%d", listingPanel.file, line.lineno))
+					}
 				}
 			}
 		}
+	})
+
+	if line, ok := listingSearchScroll(); ok {
+		if scrollbary, ok := listingPanel.vl.center(listp, line); ok {
+			listp.Scrollbar.Y = scrollbary
+			wnd.Changed()
+		}
 	}
 
-	if scrollbary != listp.Scrollbar.Y {
-		listp.Scrollbar.Y = scrollbary
-		wnd.Changed()
+	if listingPanel.recenterListing {
+		centeridx := -1
+		for i := range listingPanel.listing {
+			line := &listingPanel.listing[i]
+			if line.pc || (listingPanel.pinnedLoc != nil && line.lineno == listingPanel.pinnedLoc.Line) {
+				centeridx = i
+				break
+			}
+		}
+		if centeridx >= 0 {
+			listingPanel.recenterListing = false
+			if scrollbary, ok := listingPanel.vl.center(listp, centeridx); ok {
+				listp.Scrollbar.Y = scrollbary
+				wnd.Changed()
+			}
+		}
 	}
 }
 
+func execRunTo(loc string) {
+	scrollbackOut := editorWriter{&scrollbackEditor, false}
+	cmd := "runto " + loc
+	fmt.Fprintf(&scrollbackOut, "%s %s\n", currentPrompt(), cmd)
+	go executeCommand(cmd)
+}
+
 func listingSetBreakpoint(file string, line int) {
 	setBreakpointEx(&editorWriter{&scrollbackEditor, true}, &api.Breakpoint{File: file, Line: line})
 	refreshState(refreshToSameFrame, clearBreakpoint, nil)
@@ -1368,31 +1714,51 @@ func updateDisassemblyPanel(container *nucular.Window) {
 	}
 	addrw := nucular.FontWidth(style.Font, fmt.Sprintf("%#x", maxaddr)) + style.Text.Padding.X*2
 
-	lastfile, lastlineno := "", 0
-
 	if len(listingPanel.text) > 0 && listingPanel.text[0].Loc.Function != nil {
 		listp.Row(lineheight).Dynamic(1)
 		listp.Label(fmt.Sprintf("TEXT %s(SB) %s", listingPanel.text[0].Loc.Function.Name, listingPanel.text[0].Loc.File), "LC")
 	}
 
-	scrollbary := listp.Scrollbar.Y
-
-	for _, instr := range listingPanel.text {
+	lastfile, lastlineno := "", 0
+	centeridx := -1
+	rows := listingPanel.disasmRows[:0]
+	for i, instr := range listingPanel.text {
 		if instr.Loc.File != lastfile || instr.Loc.Line != lastlineno {
+			rows = append(rows, disasmRow{instr: i, blank: true}, disasmRow{instr: i, header: true})
+			lastfile, lastlineno = instr.Loc.File, instr.Loc.Line
+		}
+		if instr.AtPC && centeridx < 0 {
+			centeridx = len(rows)
+		}
+		rows = append(rows, disasmRow{instr: i})
+	}
+	listingPanel.disasmRows = rows
+
+	listingPanel.disasmVl.update(listp, len(rows), lineheight, func(i int) {
+		row := rows[i]
+		instr := &listingPanel.text[row.instr]
+
+		switch {
+		case row.blank:
 			listp.Row(lineheight).Dynamic(1)
+			listp.Spacing(1)
+			return
+		case row.header:
 			listp.Row(lineheight).Dynamic(1)
 			text := ""
 			if instr.Loc.File == listingPanel.file && instr.Loc.Line-1 < len(listingPanel.listing) {
 				text = strings.TrimSpace(listingPanel.listing[instr.Loc.Line-1].text)
 			}
 			listp.Label(fmt.Sprintf("%s:%d: %s", instr.Loc.File, instr.Loc.Line, text), "LC")
-			lastfile, lastlineno = instr.Loc.File, instr.Loc.Line
+			return
 		}
+
 		listp.Row(lineheight).StaticScaled(starw, arroww, addrw, 0)
 
+		rowbounds := listp.WidgetBounds()
+		rowbounds.W = listp.Bounds.W
+
 		if instr.AtPC {
-			rowbounds := listp.WidgetBounds()
-			rowbounds.W = listp.Bounds.W
 			cmds := listp.Commands()
 			cmds.FillRect(rowbounds, 0, style.Selectable.PressedActive.Data.Color)
 		}
@@ -1406,15 +1772,6 @@ func updateDisassemblyPanel(container *nucular.Window) {
 		}
 
 		if instr.AtPC {
-			if listingPanel.recenterDisassembly {
-				listingPanel.recenterDisassembly = false
-				if above, below := listp.Invisible(); above || below {
-					scrollbary = listp.At().Y - listp.Bounds.H/2
-					if scrollbary < 0 {
-						scrollbary = 0
-					}
-				}
-			}
 			iconFace, style.Font = style.Font, iconFace
 			listp.LabelColored(arrowIcon, "CC", color.RGBA{0xff, 0xff, 0x00, 0xff})
 			iconFace, style.Font = style.Font, iconFace
@@ -1424,11 +1781,23 @@ func updateDisassemblyPanel(container *nucular.Window) {
 
 		listp.Label(fmt.Sprintf("%#x", instr.Loc.PC), "LC")
 		listp.Label(instr.Text, "LC")
-	}
 
-	if scrollbary != listp.Scrollbar.Y {
-		listp.Scrollbar.Y = scrollbary
-		wnd.Changed()
+		if !running && !instr.AtPC {
+			if w := listp.ContextualOpen(0, image.Point{}, rowbounds, nil); w != nil && !BackendServer.core {
+				w.Row(20).Dynamic(1)
+				if w.MenuItem(label.TA("Run to here", "LC")) {
+					execRunTo(fmt.Sprintf("*%#x", instr.Loc.PC))
+				}
+			}
+		}
+	})
+
+	if listingPanel.recenterDisassembly && centeridx >= 0 {
+		listingPanel.recenterDisassembly = false
+		if scrollbary, ok := listingPanel.disasmVl.center(listp, centeridx); ok {
+			listp.Scrollbar.Y = scrollbary
+			wnd.Changed()
+		}
 	}
 }
 
@@ -11,6 +11,7 @@ import (
 	"runtime/pprof"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/aarzilli/gdlv/internal/assets"
 	"github.com/aarzilli/nucular"
@@ -38,6 +39,8 @@ const (
 	arrowIcon      = "\uf061"
 	breakpointIcon = "\uf28d"
 
+	disabledBreakpointIcon = "\uf28e"
+
 	interruptIcon = "\uf04c"
 	continueIcon  = "\uf04b"
 	cancelIcon    = "\uf05e"
@@ -80,11 +83,13 @@ func setupStyle() {
 const commandLineHeight = 28
 
 type listline struct {
-	idx    string
-	lineno int
-	text   string
-	pc     bool
-	bp     *api.Breakpoint
+	idx        string
+	lineno     int
+	text       string
+	pc         bool
+	bp         *api.Breakpoint
+	bpdisabled bool
+	segments   []textSegment
 }
 
 var listingPanel struct {
@@ -96,6 +101,19 @@ var listingPanel struct {
 	text                api.AsmInstructions
 	pinnedLoc           *api.Location
 	stale               bool
+	menuExpr            string
+	vl                  virtualList
+	disasmRows          []disasmRow
+	disasmVl            virtualList
+}
+
+// disasmRow is a row of the disassembly panel: either an instruction or
+// the header introducing the source line of the instructions that follow
+// it, preceded by a blank row.
+type disasmRow struct {
+	instr  int
+	header bool
+	blank  bool
 }
 
 var mu sync.Mutex
@@ -137,9 +155,12 @@ func guiUpdate(w *nucular.Window) {
 			conf.Scaling -= 0.1
 			setupStyle()
 
-		case (e.Modifiers == key.ModControl) && (e.Code == key.CodeF):
+		case (e.Modifiers == 0) && (e.Code == key.CodeF12):
 			mw.SetPerf(!mw.GetPerf())
 
+		case (e.Modifiers == key.ModControl|key.ModShift) && (e.Code == key.CodeF):
+			openFindInSources(mw, "")
+
 		case (e.Modifiers == 0) && (e.Code == key.CodeEscape):
 			mw.ActivateEditor(&commandLineEditor)
 
@@ -187,11 +208,23 @@ func updateCommandPanel(container *nucular.Window) {
 
 	style := w.Master().Style()
 
+	scrollbackSearchKeys(w)
+
 	w.LayoutReserveRow(commandLineHeight, 1)
+	sourcesChangedBar(w)
+	scrollbackSearchBar(w)
 	w.Row(0).Dynamic(1)
 	scrollbackEditor.Edit(w)
+	scrollbackLinks(w)
+
+	if commandLineEditor.Active {
+		historySearchKeys(w)
+	}
 
 	p := currentPrompt()
+	if historySearch.active {
+		p = historySearchPrompt()
+	}
 
 	promptwidth := nucular.FontWidth(style.Font, p) + style.Text.Padding.X*2
 
@@ -247,7 +280,7 @@ func updateCommandPanel(container *nucular.Window) {
 			if cmd == "" {
 				fmt.Fprintf(&scrollbackOut, "%s %s\n", p, cmdhistory[len(cmdhistory)-1])
 			} else {
-				cmdhistory = append(cmdhistory, cmd)
+				addHistory(cmd)
 				fmt.Fprintf(&scrollbackOut, "%s %s\n", p, cmd)
 			}
 			historyShown = len(cmdhistory)
@@ -382,16 +415,20 @@ func refreshState(toframe refreshToFrame, clearKind clearKind, state *api.Debugg
 	case clearBreakpoint:
 		breakpointsPanel.asyncLoad.clear()
 	case clearFrameSwitch:
+		clearHoverEval()
 		localsPanel.asyncLoad.clear()
 		exprsPanel.asyncLoad.clear()
 		listingPanel.pinnedLoc = nil
 	case clearGoroutineSwitch:
+		clearHoverEval()
 		stackPanel.asyncLoad.clear()
 		localsPanel.asyncLoad.clear()
 		exprsPanel.asyncLoad.clear()
 		regsPanel.asyncLoad.clear()
 		listingPanel.pinnedLoc = nil
 	case clearStop:
+		changedVarsStop()
+		clearHoverEval()
 		localsPanel.asyncLoad.clear()
 		exprsPanel.asyncLoad.clear()
 		regsPanel.asyncLoad.clear()
@@ -400,6 +437,7 @@ func refreshState(toframe refreshToFrame, clearKind clearKind, state *api.Debugg
 		threadsPanel.asyncLoad.clear()
 		globalsPanel.asyncLoad.clear()
 		breakpointsPanel.asyncLoad.clear()
+		memoryPanel.asyncLoad.clear()
 		listingPanel.pinnedLoc = nil
 	}
 
@@ -488,47 +526,67 @@ func refreshState(toframe refreshToFrame, clearKind clearKind, state *api.Debugg
 			failstate("ListBreakpoints()", err)
 			return
 		}
-		listingPanel.file = loc.File
-		listingPanel.abbrevFile = abbrevFileName(loc.File)
-		bpmap := map[int]*api.Breakpoint{}
-		for _, bp := range breakpoints {
-			if bp.File == loc.File {
-				bpmap[bp.Line] = bp
-			}
+		if pos, err := loadListing(loc, breakpoints, client.LastModified()); err != nil {
+			failstate(pos, err)
 		}
+	}
+}
 
-		fh, err := os.Open(loc.File)
-		if err != nil {
-			failstate("Open()", err)
-			return
+// loadListing fills the listing panel with the contents of loc.File,
+// breakpoints are the breakpoints set in delve. A zero lastModExe means
+// that there is no executable to compare the file with. In case of error
+// returns the name of the operation that failed.
+func loadListing(loc *api.Location, breakpoints []*api.Breakpoint, lastModExe time.Time) (string, error) {
+	listingPanel.file = loc.File
+	listingPanel.abbrevFile = abbrevFileName(loc.File)
+	bpmap := map[int]*api.Breakpoint{}
+	for _, bp := range breakpoints {
+		if bp.File == loc.File {
+			bpmap[bp.Line] = bp
 		}
-		defer fh.Close()
-
-		fi, _ := fh.Stat()
-		lastModExe := client.LastModified()
-		listingPanel.stale = fi.ModTime().After(lastModExe)
-
-		buf := bufio.NewScanner(fh)
-		lineno := 0
-		for buf.Scan() {
-			lineno++
-			breakpoint := bpmap[lineno]
-			listingPanel.listing = append(listingPanel.listing, listline{"", lineno, expandTabs(buf.Text()), lineno == loc.Line && listingPanel.pinnedLoc == nil, breakpoint})
+	}
+	disabledmap := map[int]bool{}
+	for _, bp := range disabledBreakpoints() {
+		if _, ok := bpmap[bp.Line]; !ok && bp.File == loc.File {
+			bpmap[bp.Line] = bp
+			disabledmap[bp.Line] = true
 		}
+	}
 
-		if err := buf.Err(); err != nil {
-			failstate("(reading file)", err)
-			return
+	fh, err := os.Open(loc.File)
+	if err != nil {
+		return "Open()", err
+	}
+	defer fh.Close()
+
+	fi, _ := fh.Stat()
+	listingPanel.stale = !lastModExe.IsZero() && fi.ModTime().After(lastModExe)
+	highlighted := highlightFile(loc.File, fi.ModTime())
+
+	buf := bufio.NewScanner(fh)
+	lineno := 0
+	for buf.Scan() {
+		lineno++
+		breakpoint := bpmap[lineno]
+		var segments []textSegment
+		if lineno-1 < len(highlighted) {
+			segments = highlighted[lineno-1]
 		}
+		listingPanel.listing = append(listingPanel.listing, listline{"", lineno, expandTabs(buf.Text()), lineno == loc.Line && listingPanel.pinnedLoc == nil, breakpoint, disabledmap[lineno], segments})
+	}
 
-		d := digits(len(listingPanel.listing))
-		if d < 3 {
-			d = 3
-		}
-		for i := range listingPanel.listing {
-			listingPanel.listing[i].idx = fmt.Sprintf("%*d", d, i+1)
-		}
+	if err := buf.Err(); err != nil {
+		return "(reading file)", err
+	}
+
+	d := digits(len(listingPanel.listing))
+	if d < 3 {
+		d = 3
+	}
+	for i := range listingPanel.listing {
+		listingPanel.listing[i].idx = fmt.Sprintf("%*d", d, i+1)
 	}
+	return "", nil
 }
 
 type editorWriter struct {
@@ -536,22 +594,24 @@ type editorWriter struct {
 	lock bool
 }
 
-const (
-	scrollbackHighMark = 8 * 1024
-	scrollbackLowMark  = 4 * 1024
-)
-
 func (w *editorWriter) Write(b []byte) (int, error) {
 	if w.lock {
 		mu.Lock()
 		defer mu.Unlock()
 		defer wnd.Changed()
 	}
-	w.ed.Buffer = append(w.ed.Buffer, []rune(expandTabs(string(b)))...)
-	if len(w.ed.Buffer) > scrollbackHighMark {
-		copy(w.ed.Buffer, w.ed.Buffer[scrollbackLowMark:])
-		w.ed.Buffer = w.ed.Buffer[:len(w.ed.Buffer)-scrollbackLowMark]
+	s := expandTabs(string(b))
+	if w.ed == &scrollbackEditor {
+		writeScrollbackLog(s)
+	}
+	w.ed.Buffer = append(w.ed.Buffer, []rune(s)...)
+	if len(w.ed.Buffer) > conf.ScrollbackHighMark {
+		copy(w.ed.Buffer, w.ed.Buffer[conf.ScrollbackLowMark:])
+		w.ed.Buffer = w.ed.Buffer[:len(w.ed.Buffer)-conf.ScrollbackLowMark]
 		w.ed.Cursor = len(w.ed.Buffer) - 256
+		if w.ed.Cursor < 0 {
+			w.ed.Cursor = 0
+		}
 	}
 	oldcursor := w.ed.Cursor
 	for w.ed.Cursor = len(w.ed.Buffer) - 2; w.ed.Cursor > oldcursor; w.ed.Cursor-- {
@@ -568,12 +628,13 @@ func (w *editorWriter) Write(b []byte) (int, error) {
 }
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage:\n\tgdlv connect <address>\n\tgdlv debug <program's arguments...>\n\tgdlv run <program file> <program's arguments...>\n\tgdlv exec <executable> <program's arguments...>\n\tgdlv test <testflags...>\n\tgdlv attach <pid>\n")
+	fmt.Fprintf(os.Stderr, "usage:\n\tgdlv connect <address>\n\tgdlv debug [options] <program's arguments...>\n\tgdlv run [options] <program file> <program's arguments...>\n\tgdlv exec [options] <executable> <program's arguments...>\n\tgdlv test [options] <testflags...>\n\tgdlv attach <pid>\n\tgdlv core <executable> <core file>\n\nOptions:\n\t--build-flags <flags>\tadditional flags for go build\n\t--tags <tags>\t\tbuild tags\n\t--wd <dir>\t\tworking directory of the program\n\t--env <NAME=value>\tenvironment variable of the program, can be repeated\n")
 	os.Exit(1)
 }
 
 func main() {
 	loadConfiguration()
+	loadHistory()
 
 	if profileEnabled {
 		if f, err := os.Create("cpu.pprof"); err == nil {
@@ -608,8 +669,10 @@ under certain conditions; see COPYING for details.
 	cmds = DebugCommands()
 
 	go BackendServer.Start()
+	go watchSources()
 
 	wnd.Main()
 
 	BackendServer.Close()
+	closeScrollbackLog()
 }
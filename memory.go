@@ -0,0 +1,297 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aarzilli/nucular"
+	"github.com/derekparker/delve/service/api"
+)
+
+const (
+	defaultMemoryLen = 256
+	maxMemoryLen     = 4096
+)
+
+var memoryWordSizes = []string{"1", "2", "4", "8"}
+
+var memoryPanel = struct {
+	asyncLoad  asyncLoad
+	addr       uint64
+	len        int
+	wordSize   int
+	data       []byte
+	err        error
+	addrEditor nucular.TextEditor
+	ed         nucular.TextEditor
+}{
+	len:        defaultMemoryLen,
+	wordSize:   1,
+	addrEditor: nucular.TextEditor{Flags: nucular.EditSelectable | nucular.EditSigEnter | nucular.EditClipboard},
+	ed:         nucular.TextEditor{Flags: nucular.EditReadOnly | nucular.EditMultiline | nucular.EditSelectable | nucular.EditClipboard},
+}
+
+// readMemory reads n bytes of memory of the target starting at addr.
+func readMemory(addr uint64, n int) ([]byte, error) {
+	cfg := api.LoadConfig{false, 0, 0, n, 0}
+	v, err := client.EvalVariable(evalScope(), fmt.Sprintf("*(*[%d]byte)(%#x)", n, addr), cfg)
+	if err != nil {
+		return nil, err
+	}
+	if v.Unreadable != "" {
+		return nil, errors.New(v.Unreadable)
+	}
+	data := make([]byte, 0, len(v.Children))
+	for i := range v.Children {
+		if v.Children[i].Unreadable != "" {
+			return nil, errors.New(v.Children[i].Unreadable)
+		}
+		b, err := strconv.ParseUint(v.Children[i].Value, 10, 8)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, byte(b))
+	}
+	return data, nil
+}
+
+// evalAddress returns the address described by arg, which can either be a
+// number or an expression evaluating to a pointer, an integer or an
+// addressable variable.
+func evalAddress(arg string) (uint64, error) {
+	if addr, err := strconv.ParseUint(arg, 0, 64); err == nil {
+		return addr, nil
+	}
+	v, err := client.EvalVariable(evalScope(), arg, ShortLoadConfig)
+	if err != nil {
+		return 0, err
+	}
+	if v.Unreadable != "" {
+		return 0, errors.New(v.Unreadable)
+	}
+	switch v.Kind {
+	case reflect.Ptr, reflect.UnsafePointer:
+		if len(v.Children) > 0 {
+			return uint64(v.Children[0].Addr), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+			return uint64(n), nil
+		}
+		return strconv.ParseUint(v.Value, 10, 64)
+	}
+	if v.Addr == 0 {
+		return 0, fmt.Errorf("can not take the address of %s", arg)
+	}
+	return uint64(v.Addr), nil
+}
+
+// formatMemory returns a hex dump of data, read at addr, grouped in words
+// of wordSize bytes, 16 bytes per line, followed by the bytes as ASCII.
+// Words are little endian, the missing bytes of an incomplete word at the
+// end of data are shown as ??.
+func formatMemory(data []byte, addr uint64, wordSize int) string {
+	const lineLen = 16
+	var buf bytes.Buffer
+	for off := 0; off < len(data); off += lineLen {
+		end := off + lineLen
+		if end > len(data) {
+			end = len(data)
+		}
+		line := data[off:end]
+		fmt.Fprintf(&buf, "%016x  ", addr+uint64(off))
+		for i := 0; i < lineLen; i += wordSize {
+			if i > 0 && i%8 == 0 {
+				buf.WriteByte(' ')
+			}
+			switch {
+			case i+wordSize <= len(line):
+				fmt.Fprintf(&buf, "%0*x ", wordSize*2, memoryWord(line[i:], wordSize))
+			case i < len(line):
+				buf.WriteString(strings.Repeat("??", i+wordSize-len(line)))
+				for j := len(line) - 1; j >= i; j-- {
+					fmt.Fprintf(&buf, "%02x", line[j])
+				}
+				buf.WriteByte(' ')
+			default:
+				buf.WriteString(strings.Repeat(" ", wordSize*2+1))
+			}
+		}
+		buf.WriteString(" |")
+		for _, b := range line {
+			if b >= 0x20 && b <= 0x7e {
+				buf.WriteByte(b)
+			} else {
+				buf.WriteByte('.')
+			}
+		}
+		buf.WriteString("|\n")
+	}
+	return buf.String()
+}
+
+func memoryWord(b []byte, wordSize int) uint64 {
+	switch wordSize {
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(b))
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(b))
+	case 8:
+		return binary.LittleEndian.Uint64(b)
+	default:
+		return uint64(b[0])
+	}
+}
+
+// parseSelectedWord parses the text selected in the memory panel as an
+// 8-byte word, either a single hexadecimal number or eight little endian
+// bytes.
+func parseSelectedWord(sel string) (uint64, bool) {
+	fields := strings.Fields(sel)
+	switch len(fields) {
+	case 1:
+		n, err := strconv.ParseUint(strings.TrimPrefix(fields[0], "0x"), 16, 64)
+		return n, err == nil
+	case 8:
+		var b [8]byte
+		for i := range fields {
+			n, err := strconv.ParseUint(fields[i], 16, 8)
+			if err != nil {
+				return 0, false
+			}
+			b[i] = byte(n)
+		}
+		return binary.LittleEndian.Uint64(b[:]), true
+	}
+	return 0, false
+}
+
+func loadMemory(p *asyncLoad) {
+	memoryPanel.data, memoryPanel.err = nil, nil
+	if memoryPanel.addr != 0 {
+		memoryPanel.data, memoryPanel.err = readMemory(memoryPanel.addr, memoryPanel.len)
+	}
+	memoryPanel.ed.Buffer = []rune(formatMemory(memoryPanel.data, memoryPanel.addr, memoryPanel.wordSize))
+	p.done(nil)
+}
+
+func setMemoryAddress(addr uint64) {
+	memoryPanel.addr = addr
+	memoryPanel.addrEditor.Buffer = []rune(fmt.Sprintf("%#x", addr))
+	memoryPanel.addrEditor.Cursor = len(memoryPanel.addrEditor.Buffer)
+	memoryPanel.asyncLoad.clear()
+}
+
+func updateMemory(container *nucular.Window) {
+	w := memoryPanel.asyncLoad.showRequest(container, nucular.WindowNoScrollbar, "memory", loadMemory)
+	if w == nil {
+		return
+	}
+	defer w.GroupEnd()
+
+	w.MenubarBegin()
+	w.Row(varRowHeight).Static(70, 0, 50, 60, 60, 60, 110)
+	w.Label("Address:", "LC")
+	if active := memoryPanel.addrEditor.Edit(w); active&nucular.EditCommitted != 0 {
+		arg := strings.TrimSpace(string(memoryPanel.addrEditor.Buffer))
+		go func() {
+			addr, err := evalAddress(arg)
+			mu.Lock()
+			if err != nil {
+				memoryPanel.err = err
+			} else {
+				setMemoryAddress(addr)
+			}
+			mu.Unlock()
+			wnd.Changed()
+		}()
+	}
+	w.Label("Word:", "RC")
+	wordSize := 0
+	for i := range memoryWordSizes {
+		if memoryWordSizes[i] == strconv.Itoa(memoryPanel.wordSize) {
+			wordSize = i
+		}
+	}
+	if newWordSize := w.ComboSimple(memoryWordSizes, wordSize, 20); newWordSize != wordSize {
+		memoryPanel.wordSize, _ = strconv.Atoi(memoryWordSizes[newWordSize])
+		memoryPanel.ed.Buffer = []rune(formatMemory(memoryPanel.data, memoryPanel.addr, memoryPanel.wordSize))
+	}
+	if w.ButtonText("Prev") && memoryPanel.addr >= uint64(memoryPanel.len) {
+		setMemoryAddress(memoryPanel.addr - uint64(memoryPanel.len))
+	}
+	if w.ButtonText("Next") && memoryPanel.addr != 0 {
+		setMemoryAddress(memoryPanel.addr + uint64(memoryPanel.len))
+	}
+	if w.ButtonText("Follow pointer") {
+		ed := &memoryPanel.ed
+		start, end := ed.SelectStart, ed.SelectEnd
+		if start > end {
+			start, end = end, start
+		}
+		if start < 0 || end > len(ed.Buffer) {
+			start, end = 0, 0
+		}
+		if addr, ok := parseSelectedWord(string(ed.Buffer[start:end])); ok {
+			setMemoryAddress(addr)
+		} else {
+			memoryPanel.err = errors.New("select an 8-byte word to follow")
+		}
+	}
+	w.MenubarEnd()
+
+	switch {
+	case memoryPanel.err != nil:
+		w.Row(varRowHeight).Dynamic(1)
+		w.Label(fmt.Sprintf("Error: %v", memoryPanel.err), "LC")
+	case memoryPanel.addr == 0:
+		w.Row(varRowHeight).Dynamic(1)
+		w.Label("Enter an address or use the x command", "LC")
+	}
+
+	w.Row(0).Dynamic(1)
+	memoryPanel.ed.Edit(w)
+}
+
+func examineMemoryCommand(out io.Writer, args string) error {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		return errors.New("not enough arguments")
+	}
+
+	n := defaultMemoryLen
+	if i := strings.LastIndex(args, " "); i >= 0 {
+		if l, err := strconv.Atoi(args[i+1:]); err == nil {
+			n = l
+			args = strings.TrimSpace(args[:i])
+		}
+	}
+	if n <= 0 || n > maxMemoryLen {
+		return fmt.Errorf("length must be between 1 and %d", maxMemoryLen)
+	}
+
+	addr, err := evalAddress(args)
+	if err != nil {
+		return err
+	}
+	data, err := readMemory(addr, n)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, formatMemory(data, addr, 1))
+
+	mu.Lock()
+	memoryPanel.len = n
+	setMemoryAddress(addr)
+	mu.Unlock()
+	wnd.Changed()
+	return nil
+}
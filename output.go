@@ -0,0 +1,335 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aarzilli/nucular"
+	"github.com/aarzilli/nucular/clipboard"
+	"github.com/aarzilli/nucular/label"
+	"github.com/aarzilli/nucular/rect"
+	nstyle "github.com/aarzilli/nucular/style"
+)
+
+// The standard output and standard error of the target are shown in the
+// Output panel, separately from the messages of the debugger.
+
+const (
+	maxOutputLines = 10000
+	// when more than maxOutputRate bytes are written in outputRateInterval
+	// the output of the target is silenced
+	maxOutputRate      = 64 * 1024
+	outputRateInterval = 500 * time.Millisecond
+)
+
+type outputSegment struct {
+	text  string
+	color color.RGBA
+	// plain segments are drawn with the default colour
+	plain bool
+}
+
+type outputLine struct {
+	segments []outputSegment
+	stderr   bool
+}
+
+// ansiState is the current graphic rendition of a stream, as set by ANSI
+// escape sequences.
+type ansiState struct {
+	color color.RGBA
+	set   bool
+	bold  bool
+}
+
+var stderrColor = color.RGBA{0xff, 0x60, 0x60, 0xff}
+
+var ansiColors = [16]color.RGBA{
+	{0x00, 0x00, 0x00, 0xff}, {0xcd, 0x00, 0x00, 0xff}, {0x00, 0xcd, 0x00, 0xff}, {0xcd, 0xcd, 0x00, 0xff},
+	{0x00, 0x00, 0xee, 0xff}, {0xcd, 0x00, 0xcd, 0xff}, {0x00, 0xcd, 0xcd, 0xff}, {0xe5, 0xe5, 0xe5, 0xff},
+	{0x7f, 0x7f, 0x7f, 0xff}, {0xff, 0x00, 0x00, 0xff}, {0x00, 0xff, 0x00, 0xff}, {0xff, 0xff, 0x00, 0xff},
+	{0x5c, 0x5c, 0xff, 0xff}, {0xff, 0x00, 0xff, 0xff}, {0x00, 0xff, 0xff, 0xff}, {0xff, 0xff, 0xff, 0xff},
+}
+
+// outputPanel is protected by mu.
+var outputPanel = struct {
+	lines []outputLine
+	// graphic rendition of stdout and stderr
+	ansi [2]ansiState
+
+	// number of bytes written since t0, used to silence the target when
+	// it writes too much
+	bucket int
+	t0     time.Time
+
+	vl virtualList
+	// number of lines shown during the last frame
+	shown int
+
+	stdin nucular.TextEditor
+}{
+	stdin: nucular.TextEditor{Flags: nucular.EditSelectable | nucular.EditSigEnter | nucular.EditClipboard},
+}
+
+// appendOutput adds a line written by the target to the Output panel.
+func appendOutput(text string, stderr bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	defer wnd.Changed()
+
+	if silenced {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(outputPanel.t0) > outputRateInterval {
+		outputPanel.t0 = now
+		outputPanel.bucket = 0
+	}
+	outputPanel.bucket += len(text)
+	if outputPanel.bucket > maxOutputRate {
+		silenced = true
+		appendOutputLine(outputLine{segments: []outputSegment{{text: fmt.Sprintf("too much output in %v (%d), output silenced", outputRateInterval, outputPanel.bucket), color: stderrColor}}})
+		outputPanel.bucket = 0
+		return
+	}
+
+	stream := 0
+	if stderr {
+		stream = 1
+	}
+	line := outputLine{stderr: stderr}
+	line.segments = parseANSI(expandTabs(text), &outputPanel.ansi[stream])
+	appendOutputLine(line)
+
+	if !rootPanel.shows(infoOutput) {
+		// without an Output panel the output would be invisible
+		scrollbackOut := editorWriter{&scrollbackEditor, false}
+		fmt.Fprintln(&scrollbackOut, line.String())
+	}
+}
+
+func appendOutputLine(line outputLine) {
+	if len(outputPanel.lines) >= maxOutputLines {
+		n := copy(outputPanel.lines, outputPanel.lines[maxOutputLines/10:])
+		outputPanel.lines = outputPanel.lines[:n]
+	}
+	outputPanel.lines = append(outputPanel.lines, line)
+}
+
+// parseANSI splits text into segments of the same colour, interpreting the
+// SGR escape sequences it contains and removing all other escape
+// sequences.
+func parseANSI(text string, state *ansiState) []outputSegment {
+	var segments []outputSegment
+	var buf bytes.Buffer
+	flush := func() {
+		if buf.Len() == 0 {
+			return
+		}
+		seg := outputSegment{text: buf.String(), color: state.color, plain: !state.set}
+		if state.bold && state.set {
+			for i := 0; i < 8; i++ {
+				if seg.color == ansiColors[i] {
+					seg.color = ansiColors[i+8]
+				}
+			}
+		}
+		segments = append(segments, seg)
+		buf.Reset()
+	}
+
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\x1b' && i+1 < len(text) && text[i+1] == '[':
+			j := i + 2
+			for j < len(text) && (text[j] < 0x40 || text[j] > 0x7e) {
+				j++
+			}
+			if j >= len(text) {
+				i = len(text)
+				break
+			}
+			if text[j] == 'm' {
+				flush()
+				applySGR(text[i+2:j], state)
+			}
+			i = j
+		case text[i] == '\x1b' || text[i] == '\r':
+			// drop
+		default:
+			buf.WriteByte(text[i])
+		}
+	}
+	flush()
+	return segments
+}
+
+func applySGR(params string, state *ansiState) {
+	v := strings.Split(params, ";")
+	for i := 0; i < len(v); i++ {
+		n, _ := strconv.Atoi(v[i])
+		switch {
+		case n == 0:
+			*state = ansiState{}
+		case n == 1:
+			state.bold = true
+		case n == 22:
+			state.bold = false
+		case n >= 30 && n <= 37:
+			state.color, state.set = ansiColors[n-30], true
+		case n >= 90 && n <= 97:
+			state.color, state.set = ansiColors[n-90+8], true
+		case n == 39:
+			state.set = false
+		case n == 38 && i+2 < len(v) && v[i+1] == "5":
+			c, _ := strconv.Atoi(v[i+2])
+			state.color, state.set = ansi256Color(c), true
+			i += 2
+		case n == 38 && i+4 < len(v) && v[i+1] == "2":
+			r, _ := strconv.Atoi(v[i+2])
+			g, _ := strconv.Atoi(v[i+3])
+			b, _ := strconv.Atoi(v[i+4])
+			state.color, state.set = color.RGBA{uint8(r), uint8(g), uint8(b), 0xff}, true
+			i += 4
+		}
+	}
+}
+
+func ansi256Color(c int) color.RGBA {
+	switch {
+	case c < 0:
+		return ansiColors[0]
+	case c < 16:
+		return ansiColors[c]
+	case c < 232:
+		c -= 16
+		level := func(x int) uint8 {
+			if x == 0 {
+				return 0
+			}
+			return uint8(55 + x*40)
+		}
+		return color.RGBA{level(c / 36), level((c / 6) % 6), level(c % 6), 0xff}
+	case c < 256:
+		g := uint8(8 + (c-232)*10)
+		return color.RGBA{g, g, g, 0xff}
+	}
+	return ansiColors[15]
+}
+
+func (line *outputLine) String() string {
+	var buf bytes.Buffer
+	for _, seg := range line.segments {
+		buf.WriteString(seg.text)
+	}
+	return buf.String()
+}
+
+func outputLabel(w *nucular.Window, line *outputLine) {
+	bounds, out := w.Custom(nstyle.WidgetStateInactive)
+	if out == nil {
+		return
+	}
+	style := w.Master().Style()
+	fh := nucular.FontHeight(style.Font)
+	r := rect.Rect{X: bounds.X + style.Text.Padding.X, Y: bounds.Y + bounds.H/2 - fh/2, H: 2 * fh}
+	for _, seg := range line.segments {
+		r.W = nucular.FontWidth(style.Font, seg.text)
+		c := seg.color
+		if seg.plain {
+			c = style.Text.Color
+			if line.stderr {
+				c = stderrColor
+			}
+		}
+		out.DrawText(r, seg.text, style.Font, c)
+		r.X += r.W
+	}
+}
+
+func sendStdin(text string) {
+	if BackendServer.stdin == nil {
+		return
+	}
+	appendOutputLine(outputLine{segments: []outputSegment{{text: expandTabs(text), plain: true}}})
+	// writing blocks if the target isn't reading its standard input
+	stdin := BackendServer.stdin
+	go func() {
+		if _, err := io.WriteString(stdin, text+"\n"); err != nil {
+			mu.Lock()
+			appendOutputLine(outputLine{segments: []outputSegment{{text: fmt.Sprintf("could not write to stdin: %v", err), color: stderrColor}}})
+			mu.Unlock()
+			wnd.Changed()
+		}
+	}()
+}
+
+func updateOutput(container *nucular.Window) {
+	w := container.GroupBegin("output", nucular.WindowNoScrollbar)
+	if w == nil {
+		return
+	}
+	defer w.GroupEnd()
+
+	w.MenubarBegin()
+	w.Row(varRowHeight).Static(100, 100)
+	if w.ButtonText("Clear") {
+		outputPanel.lines = outputPanel.lines[:0]
+	}
+	w.CheckboxText("Silenced", &silenced)
+	w.MenubarEnd()
+
+	w.LayoutReserveRow(commandLineHeight, 1)
+	w.Row(0).Dynamic(1)
+	if g := w.GroupBegin("output-lines", 0); g != nil {
+		// keep showing the last line if it was visible
+		n := len(outputPanel.lines)
+		vl := &outputPanel.vl
+		if n > outputPanel.shown && vl.pitch > 0 && g.Scrollbar.Y+vl.height >= vl.start+outputPanel.shown*vl.pitch-vl.pitch/2 {
+			if y := vl.start + n*vl.pitch - vl.height; y > 0 {
+				g.Scrollbar.Y = y
+			}
+		}
+		outputPanel.shown = n
+
+		vl.update(g, n, varRowHeight, func(i int) {
+			g.Row(varRowHeight).Dynamic(1)
+			outputLabel(g, &outputPanel.lines[i])
+			if w := g.ContextualOpen(0, image.Point{}, g.LastWidgetBounds, nil); w != nil {
+				w.Row(20).Dynamic(1)
+				if w.MenuItem(label.TA("Copy to clipboard", "LC")) {
+					clipboard.Set(outputPanel.lines[i].String())
+				}
+			}
+		})
+		g.GroupEnd()
+	}
+
+	if BackendServer.stdin == nil {
+		w.Row(commandLineHeight).Dynamic(1)
+		w.Label("Standard input not available", "LC")
+		return
+	}
+	w.Row(commandLineHeight).Static(60, 0, 60)
+	w.Label("stdin:", "LC")
+	active := outputPanel.stdin.Edit(w)
+	if active&nucular.EditCommitted != 0 {
+		sendStdin(string(outputPanel.stdin.Buffer))
+		outputPanel.stdin.Buffer = outputPanel.stdin.Buffer[:0]
+		outputPanel.stdin.Cursor = 0
+		outputPanel.stdin.Active = true
+	}
+	if w.ButtonText("EOF") {
+		BackendServer.stdin.Close()
+		BackendServer.stdin = nil
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestParseANSI(t *testing.T) {
+	red, brightRed := ansiColors[1], ansiColors[9]
+	tests := []struct {
+		in       string
+		state    ansiState
+		segments []outputSegment
+		after    ansiState
+	}{
+		{"plain", ansiState{}, []outputSegment{{text: "plain", plain: true}}, ansiState{}},
+		{"", ansiState{}, nil, ansiState{}},
+		{"a\x1b[31mb\x1b[0mc", ansiState{}, []outputSegment{{text: "a", plain: true}, {text: "b", color: red}, {text: "c", plain: true}}, ansiState{}},
+		{"\x1b[1;31mx", ansiState{}, []outputSegment{{text: "x", color: brightRed}}, ansiState{color: red, set: true, bold: true}},
+		{"\x1b[91mx\x1b[39my", ansiState{}, []outputSegment{{text: "x", color: brightRed}, {text: "y", color: brightRed, plain: true}}, ansiState{color: brightRed}},
+		{"\x1b[38;5;196mx", ansiState{}, []outputSegment{{text: "x", color: color.RGBA{0xff, 0, 0, 0xff}}}, ansiState{color: color.RGBA{0xff, 0, 0, 0xff}, set: true}},
+		{"\x1b[38;2;1;2;3mx", ansiState{}, []outputSegment{{text: "x", color: color.RGBA{1, 2, 3, 0xff}}}, ansiState{color: color.RGBA{1, 2, 3, 0xff}, set: true}},
+		{"a\x1b[2Kb\rc\x1bd", ansiState{}, []outputSegment{{text: "abcd", plain: true}}, ansiState{}},
+		{"a\x1b[31", ansiState{}, []outputSegment{{text: "a", plain: true}}, ansiState{}},
+		// the state carries over from the previous line
+		{"x", ansiState{color: red, set: true}, []outputSegment{{text: "x", color: red}}, ansiState{color: red, set: true}},
+	}
+	for _, tc := range tests {
+		state := tc.state
+		segments := parseANSI(tc.in, &state)
+		if !reflect.DeepEqual(segments, tc.segments) {
+			t.Errorf("parseANSI(%q) = %#v, expected %#v", tc.in, segments, tc.segments)
+		}
+		if state != tc.after {
+			t.Errorf("parseANSI(%q): state %#v, expected %#v", tc.in, state, tc.after)
+		}
+	}
+}
+
+func TestANSI256Color(t *testing.T) {
+	tests := []struct {
+		c   int
+		out color.RGBA
+	}{
+		{1, ansiColors[1]},
+		{16, color.RGBA{0, 0, 0, 0xff}},
+		{231, color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{232, color.RGBA{8, 8, 8, 0xff}},
+		{255, color.RGBA{238, 238, 238, 0xff}},
+		{300, ansiColors[15]},
+	}
+	for _, tc := range tests {
+		if out := ansi256Color(tc.c); out != tc.out {
+			t.Errorf("ansi256Color(%d) = %v, expected %v", tc.c, out, tc.out)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aarzilli/nucular"
+	"github.com/aarzilli/nucular/rect"
+)
+
+type runConfigWindow struct {
+	args, buildFlags, tags, wd, env nucular.TextEditor
+	err                             error
+}
+
+func runConfigCommand(out io.Writer, args string) error {
+	if !BackendServer.canConfigure() {
+		return errors.New("the run configuration can only be changed when gdlv starts the target")
+	}
+	rw := &runConfigWindow{}
+	for _, ed := range []*nucular.TextEditor{&rw.args, &rw.buildFlags, &rw.tags, &rw.wd} {
+		ed.Flags = nucular.EditSelectable | nucular.EditClipboard
+	}
+	rw.env.Flags = nucular.EditSelectable | nucular.EditClipboard | nucular.EditMultiline
+	rw.args.Buffer = []rune(joinQuotedFields(BackendServer.args))
+	rw.buildFlags.Buffer = []rune(BackendServer.buildFlags)
+	rw.tags.Buffer = []rune(BackendServer.tags)
+	rw.wd.Buffer = []rune(BackendServer.wd)
+	rw.env.Buffer = []rune(strings.Join(BackendServer.env, "\n"))
+	wnd.PopupOpen("Run configuration", dynamicPopupFlags, rect.Rect{100, 100, 600, 400}, true, rw.update)
+	return nil
+}
+
+func (rw *runConfigWindow) update(w *nucular.Window) {
+	const col1 = 120
+	w.Row(20).Static(col1, 0)
+	w.Label("Arguments:", "LC")
+	rw.args.Edit(w)
+	if BackendServer.mode != "exec" {
+		w.Row(20).Static(col1, 0)
+		w.Label("Build flags:", "LC")
+		rw.buildFlags.Edit(w)
+		w.Row(20).Static(col1, 0)
+		w.Label("Build tags:", "LC")
+		rw.tags.Edit(w)
+	}
+	w.Row(20).Static(col1, 0)
+	w.Label("Working directory:", "LC")
+	rw.wd.Edit(w)
+	w.Row(20).Dynamic(1)
+	w.Label("Environment (one NAME=value per line):", "LC")
+	w.Row(100).Dynamic(1)
+	rw.env.Edit(w)
+
+	if rw.err != nil {
+		w.Row(20).Dynamic(1)
+		w.Label(fmt.Sprintf("Error: %v", rw.err), "LC")
+	}
+
+	w.Row(20).Static(0, 100, 100)
+	w.Spacing(1)
+	if w.ButtonText("Cancel") {
+		w.Close()
+	}
+	if w.ButtonText("OK") {
+		rw.err = rw.apply()
+		if rw.err == nil {
+			w.Close()
+		}
+	}
+}
+
+// apply saves the run configuration in BackendServer, it will be used by
+// the next restart.
+func (rw *runConfigWindow) apply() error {
+	var env []string
+	for _, line := range strings.Split(string(rw.env.Buffer), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.Contains(line, "=") {
+			return fmt.Errorf("malformed environment variable %q", line)
+		}
+		env = append(env, line)
+	}
+	wd := strings.TrimSpace(string(rw.wd.Buffer))
+	args := splitQuotedFields(string(rw.args.Buffer))
+
+	if wd != BackendServer.wd || strings.Join(env, "\n") != strings.Join(BackendServer.env, "\n") || joinQuotedFields(args) != joinQuotedFields(BackendServer.args) {
+		// delve can not change the arguments of the target and passes its
+		// own environment and working directory to it when restarting it
+		BackendServer.restartServer = true
+	}
+	BackendServer.setArgs(args)
+	BackendServer.buildFlags = strings.TrimSpace(string(rw.buildFlags.Buffer))
+	BackendServer.tags = strings.TrimSpace(string(rw.tags.Buffer))
+	BackendServer.wd = wd
+	BackendServer.env = env
+	BackendServer.updateCommandLine()
+
+	scrollbackOut := editorWriter{&scrollbackEditor, false}
+	fmt.Fprintf(&scrollbackOut, "Run configuration changed, it will be used by the next restart\n")
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Command scripts are text files containing one command per line, empty
+// lines and lines starting with '#' are ignored.
+
+const initScriptName = ".gdlvinit"
+
+// maxScriptDepth limits how many scripts can be nested with the source
+// command, to stop scripts that source themselves.
+const maxScriptDepth = 20
+
+var scriptDepth int
+
+// initScriptsDone is set after the init scripts are executed, they must
+// not run again when gdlv reconnects after a restart.
+var initScriptsDone bool
+
+func sourceCommand(out io.Writer, args string) error {
+	if args == "" {
+		return errors.New("not enough arguments")
+	}
+	return runScript(out, args)
+}
+
+// runScript executes the commands in path, stopping at the first command
+// that fails.
+func runScript(out io.Writer, path string) error {
+	if scriptDepth >= maxScriptDepth {
+		return fmt.Errorf("%s: too many nested scripts", path)
+	}
+	scriptDepth++
+	defer func() { scriptDepth-- }()
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	scan := bufio.NewScanner(fh)
+	lineno := 0
+	for scan.Scan() {
+		lineno++
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fmt.Fprintf(out, "> %s\n", line)
+		cmdstr, args := parseCommand(line)
+		if err := cmds.Call(cmdstr, args, out); err != nil {
+			if _, ok := err.(ExitRequestError); ok {
+				return err
+			}
+			return fmt.Errorf("%s:%d: %v", path, lineno, err)
+		}
+	}
+	return scan.Err()
+}
+
+// initScriptLocs returns the init scripts that should be executed after
+// connecting to the target, the global one first.
+func initScriptLocs() []string {
+	r := []string{configLoc() + "init"}
+	if wd, err := os.Getwd(); err == nil {
+		r = append(r, filepath.Join(wd, initScriptName))
+	}
+	return r
+}
+
+// runInitScripts executes the init scripts that exist, the first time it's
+// called.
+func runInitScripts() {
+	if initScriptsDone {
+		return
+	}
+	initScriptsDone = true
+	out := editorWriter{&scrollbackEditor, true}
+	for _, path := range initScriptLocs() {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		fmt.Fprintf(&out, "Running %s\n", path)
+
+		mu.Lock()
+		running = true
+		wnd.Changed()
+		mu.Unlock()
+
+		err := runScript(&out, path)
+
+		mu.Lock()
+		running = false
+		wnd.Changed()
+		mu.Unlock()
+
+		if _, ok := err.(ExitRequestError); ok {
+			exitRequested()
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(&out, "Command failed: %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aarzilli/nucular"
+	"github.com/derekparker/delve/service/api"
+
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/mouse"
+)
+
+const (
+	defaultScrollbackHighMark = 8 * 1024
+	minScrollbackHighMark     = 1024
+)
+
+// Everything written to the scrollback is also written to a log on disk,
+// so that it can be saved after it's been truncated. The log is split in
+// two segments, when the current segment becomes larger than
+// scrollbackLogSegment it replaces the old one.
+// The log is only accessed by a goroutine that executes the functions sent
+// on scrollbackLog.ops, so that writing to the scrollback doesn't do I/O
+// while holding mu.
+
+const scrollbackLogSegment = 8 * 1024 * 1024
+
+var scrollbackLog = struct {
+	start  sync.Once
+	ops    chan func()
+	dir    string
+	cur    *os.File
+	size   int
+	failed bool
+}{
+	ops: make(chan func(), 256),
+}
+
+// doScrollbackLog executes fn on the goroutine that owns the scrollback log.
+func doScrollbackLog(fn func()) {
+	scrollbackLog.start.Do(func() {
+		go func() {
+			for fn := range scrollbackLog.ops {
+				fn()
+			}
+		}()
+	})
+	scrollbackLog.ops <- fn
+}
+
+func scrollbackLogSegments() [2]string {
+	return [2]string{filepath.Join(scrollbackLog.dir, "scrollback.0"), filepath.Join(scrollbackLog.dir, "scrollback.1")}
+}
+
+func writeScrollbackLog(s string) {
+	doScrollbackLog(func() { appendScrollbackLog(s) })
+}
+
+func appendScrollbackLog(s string) {
+	if scrollbackLog.failed {
+		return
+	}
+	segs := scrollbackLogSegments()
+	if scrollbackLog.cur == nil || scrollbackLog.size > scrollbackLogSegment {
+		var err error
+		if scrollbackLog.dir == "" {
+			scrollbackLog.dir, err = ioutil.TempDir("", "gdlv")
+			if err != nil {
+				scrollbackLog.failed = true
+				return
+			}
+			segs = scrollbackLogSegments()
+		}
+		if scrollbackLog.cur != nil {
+			scrollbackLog.cur.Close()
+			os.Rename(segs[1], segs[0])
+		}
+		scrollbackLog.cur, err = os.Create(segs[1])
+		if err != nil {
+			scrollbackLog.failed = true
+			return
+		}
+		scrollbackLog.size = 0
+	}
+	n, _ := io.WriteString(scrollbackLog.cur, s)
+	scrollbackLog.size += n
+}
+
+// saveScrollbackLog writes the contents of the scrollback log to path.
+func saveScrollbackLog(path string) error {
+	done := make(chan error)
+	doScrollbackLog(func() { done <- copyScrollbackLog(path) })
+	return <-done
+}
+
+func copyScrollbackLog(path string) error {
+	if scrollbackLog.failed {
+		return errors.New("scrollback log not available")
+	}
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	if scrollbackLog.dir == "" {
+		return nil
+	}
+	for _, seg := range scrollbackLogSegments() {
+		in, err := os.Open(seg)
+		if err != nil {
+			continue
+		}
+		_, err = io.Copy(fh, in)
+		in.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func closeScrollbackLog() {
+	done := make(chan struct{})
+	doScrollbackLog(func() {
+		if scrollbackLog.cur != nil {
+			scrollbackLog.cur.Close()
+			scrollbackLog.cur = nil
+		}
+		if scrollbackLog.dir != "" {
+			os.RemoveAll(scrollbackLog.dir)
+		}
+		scrollbackLog.failed = true
+		close(done)
+	})
+	<-done
+}
+
+func scrollSizeCommand(out io.Writer, args string) error {
+	if args != "" {
+		v := strings.Fields(args)
+		if len(v) != 2 {
+			return errors.New("wrong number of arguments")
+		}
+		high, err1 := strconv.Atoi(v[0])
+		low, err2 := strconv.Atoi(v[1])
+		if err1 != nil || err2 != nil {
+			return errors.New("arguments must be numbers")
+		}
+		if high < minScrollbackHighMark || low <= 0 || low >= high {
+			return fmt.Errorf("high mark must be at least %d and greater than the low mark", minScrollbackHighMark)
+		}
+		mu.Lock()
+		conf.ScrollbackHighMark, conf.ScrollbackLowMark = high, low
+		mu.Unlock()
+		saveConfiguration()
+	}
+	fmt.Fprintf(out, "Scrollback is truncated by %d characters when it's longer than %d characters\n", conf.ScrollbackLowMark, conf.ScrollbackHighMark)
+	return nil
+}
+
+var scrollbackSearch = struct {
+	active bool
+	ed     nucular.TextEditor
+
+	query   string
+	buflen  int
+	qlen    int
+	matches []int
+	cur     int
+}{
+	ed: nucular.TextEditor{Flags: nucular.EditSelectable | nucular.EditSigEnter | nucular.EditClipboard},
+}
+
+// scrollbackSearchKeys handles the keyboard shortcuts of the scrollback
+// search when the mouse is over the command panel.
+func scrollbackSearchKeys(w *nucular.Window) {
+	kbd := w.KeyboardOnHover(w.Bounds)
+	for _, k := range kbd.Keys {
+		switch {
+		case k.Modifiers == key.ModControl && k.Code == key.CodeF:
+			if !scrollbackSearch.active {
+				scrollbackSearch.ed.Buffer = []rune(scrollbackSearch.query)
+				scrollbackSearch.ed.Cursor = len(scrollbackSearch.ed.Buffer)
+				scrollbackSearch.ed.CursorFollow = true
+			}
+			scrollbackSearch.active = true
+			w.Master().ActivateEditor(&scrollbackSearch.ed)
+		case k.Modifiers == 0 && k.Code == key.CodeF3:
+			nextScrollbackMatch(1)
+		case k.Modifiers == key.ModShift && k.Code == key.CodeF3:
+			nextScrollbackMatch(-1)
+		}
+	}
+}
+
+// scrollbackSearchBar shows the search bar above the scrollback.
+func scrollbackSearchBar(w *nucular.Window) {
+	if !scrollbackSearch.active {
+		return
+	}
+
+	if scrollbackSearch.ed.Active {
+		for _, k := range w.Input().Keyboard.Keys {
+			if k.Modifiers == 0 && k.Code == key.CodeEscape {
+				scrollbackSearch.active = false
+				return
+			}
+		}
+	}
+
+	w.Row(varRowHeight).Static(50, 0, 80, 60, 60, 30)
+	w.Label("Find:", "LC")
+	active := scrollbackSearch.ed.Edit(w)
+	updateScrollbackMatches(string(scrollbackSearch.ed.Buffer))
+	if len(scrollbackSearch.matches) > 0 {
+		w.Label(fmt.Sprintf("%d/%d", scrollbackSearch.cur+1, len(scrollbackSearch.matches)), "LC")
+	} else {
+		w.Label("no match", "LC")
+	}
+	if w.ButtonText("Prev") || active&nucular.EditCommitted != 0 {
+		nextScrollbackMatch(-1)
+	}
+	if w.ButtonText("Next") {
+		nextScrollbackMatch(1)
+	}
+	if w.ButtonText("x") {
+		scrollbackSearch.active = false
+	}
+}
+
+// updateScrollbackMatches finds all occurrences of query in the scrollback.
+// The search is case insensitive unless query contains upper case letters.
+func updateScrollbackMatches(query string) {
+	buf := scrollbackEditor.Buffer
+	if query == scrollbackSearch.query && len(buf) == scrollbackSearch.buflen {
+		return
+	}
+	queryChanged := query != scrollbackSearch.query
+	scrollbackSearch.query = query
+	scrollbackSearch.buflen = len(buf)
+	scrollbackSearch.qlen = utf8.RuneCountInString(query)
+	scrollbackSearch.matches = scrollbackSearch.matches[:0]
+	if query == "" {
+		return
+	}
+
+	fold := strings.IndexFunc(query, unicode.IsUpper) < 0
+	if fold {
+		query = strings.ToLower(query)
+	}
+	q := []rune(query)
+	for i := 0; i+len(q) <= len(buf); i++ {
+		match := true
+		for j := range q {
+			ch := buf[i+j]
+			if fold {
+				ch = unicode.ToLower(ch)
+			}
+			if ch != q[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			scrollbackSearch.matches = append(scrollbackSearch.matches, i)
+			i += len(q) - 1
+		}
+	}
+
+	// the most recent output is at the bottom of the scrollback, start
+	// from there
+	if scrollbackSearch.cur >= len(scrollbackSearch.matches) || queryChanged {
+		scrollbackSearch.cur = len(scrollbackSearch.matches) - 1
+		showScrollbackMatch()
+	}
+}
+
+func nextScrollbackMatch(dir int) {
+	n := len(scrollbackSearch.matches)
+	if n == 0 {
+		return
+	}
+	scrollbackSearch.cur = (scrollbackSearch.cur + dir + n) % n
+	showScrollbackMatch()
+}
+
+// showScrollbackMatch selects the current match in the scrollback and
+// scrolls to it.
+func showScrollbackMatch() {
+	if scrollbackSearch.cur < 0 || scrollbackSearch.cur >= len(scrollbackSearch.matches) {
+		return
+	}
+	start := scrollbackSearch.matches[scrollbackSearch.cur]
+	scrollbackEditor.SelectStart = start
+	scrollbackEditor.SelectEnd = start + scrollbackSearch.qlen
+	scrollbackEditor.Cursor = start
+	scrollbackEditor.CursorFollow = true
+}
+
+// Clicking on a file:line location or on an address printed in the
+// scrollback shows it in the listing and disassembly panels.
+
+var (
+	fileLineRx = regexp.MustCompile(`^(.+\.(?:go|s|c|h)):(\d+)`)
+	pcRx       = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+)
+
+// scrollbackLinks follows the link under the cursor of the scrollback if
+// it was just clicked.
+func scrollbackLinks(w *nucular.Window) {
+	ed := &scrollbackEditor
+	if !w.Input().Mouse.Clicked(mouse.ButtonLeft, w.LastWidgetBounds) || ed.SelectStart != ed.SelectEnd {
+		return
+	}
+	if word := wordAt(ed.Buffer, ed.Cursor); word != "" {
+		go followScrollbackLink(word)
+	}
+}
+
+func isLinkDelimiter(ch rune) bool {
+	return unicode.IsSpace(ch) || strings.IndexRune("()[]{}<>,;=\"'`", ch) >= 0
+}
+
+// wordAt returns the word containing the character at position i of buf.
+func wordAt(buf []rune, i int) string {
+	if i < 0 || i >= len(buf) || isLinkDelimiter(buf[i]) {
+		return ""
+	}
+	start, end := i, i
+	for start > 0 && !isLinkDelimiter(buf[start-1]) {
+		start--
+	}
+	for end < len(buf) && !isLinkDelimiter(buf[end]) {
+		end++
+	}
+	return strings.TrimRight(string(buf[start:end]), ":.")
+}
+
+// linkLocation returns the location described by word, which is either a
+// path followed by a line number or an address.
+func linkLocation(word string) *api.Location {
+	if pcRx.MatchString(word) {
+		locs, err := client.FindLocation(evalScope(), "*"+word)
+		if err != nil || len(locs) != 1 {
+			return nil
+		}
+		return &locs[0]
+	}
+
+	m := fileLineRx.FindStringSubmatch(word)
+	if m == nil {
+		return nil
+	}
+	line, err := strconv.Atoi(m[2])
+	if err != nil {
+		return nil
+	}
+	path := m[1]
+	if !filepath.IsAbs(path) {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+	if _, err := os.Stat(path); err != nil {
+		// the path could be relative to a directory other than the current
+		// one, look for it in the sources of the target
+		suffix := "/" + strings.TrimPrefix(filepath.ToSlash(m[1]), "./")
+		path = ""
+		for _, file := range sourcesPanel.slice {
+			if strings.HasSuffix(filepath.ToSlash(file), suffix) {
+				path = file
+				break
+			}
+		}
+		if path == "" {
+			return nil
+		}
+	}
+	return &api.Location{File: path, Line: line}
+}
+
+func followScrollbackLink(word string) {
+	if client == nil {
+		return
+	}
+	loc := linkLocation(word)
+	if loc == nil {
+		return
+	}
+	listingPanel.pinnedLoc = loc
+	refreshState(refreshToSameFrame, clearNothing, nil)
+}
@@ -0,0 +1,343 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aarzilli/nucular"
+	"github.com/aarzilli/nucular/rect"
+	"github.com/derekparker/delve/service/api"
+
+	"golang.org/x/mobile/event/key"
+)
+
+type listingBarMode uint8
+
+const (
+	listingBarHidden listingBarMode = iota
+	listingBarSearch
+	listingBarGotoLine
+)
+
+type listingMatch struct {
+	line, col, len int
+}
+
+var listingSearch = struct {
+	mode listingBarMode
+	ed   nucular.TextEditor
+
+	query   string
+	file    string
+	nlines  int
+	matches []listingMatch
+	cur     int
+
+	// scroll is set when the listing should be scrolled to show the
+	// current match
+	scroll bool
+}{
+	ed: nucular.TextEditor{Flags: nucular.EditSelectable | nucular.EditSigEnter | nucular.EditClipboard},
+}
+
+var matchColor = color.RGBA{0x60, 0x4c, 0x00, 0x60}
+var curMatchColor = color.RGBA{0xa0, 0x50, 0x00, 0xa0}
+
+// listingSearchKeys handles the keyboard shortcuts of the listing panel.
+func listingSearchKeys(listp *nucular.Window) {
+	mw := listp.Master()
+	kbd := listp.KeyboardOnHover(listp.Bounds)
+	for _, k := range kbd.Keys {
+		switch {
+		case k.Modifiers == key.ModControl && k.Code == key.CodeF:
+			openListingBar(mw, listingBarSearch)
+		case k.Modifiers == key.ModControl && k.Code == key.CodeG:
+			openListingBar(mw, listingBarGotoLine)
+		case k.Modifiers == 0 && k.Code == key.CodeF3:
+			nextListingMatch(1)
+		case k.Modifiers == key.ModShift && k.Code == key.CodeF3:
+			nextListingMatch(-1)
+		}
+	}
+}
+
+func openListingBar(mw nucular.MasterWindow, mode listingBarMode) {
+	if listingSearch.mode != mode {
+		listingSearch.ed.Buffer = listingSearch.ed.Buffer[:0]
+		if mode == listingBarSearch {
+			listingSearch.ed.Buffer = append(listingSearch.ed.Buffer, []rune(listingSearch.query)...)
+		}
+		listingSearch.ed.Cursor = len(listingSearch.ed.Buffer)
+		listingSearch.ed.CursorFollow = true
+	}
+	listingSearch.mode = mode
+	mw.ActivateEditor(&listingSearch.ed)
+}
+
+// listingSearchBar shows the search or go to line bar at the top of the
+// listing panel.
+func listingSearchBar(listp *nucular.Window) {
+	if listingSearch.mode == listingBarHidden {
+		return
+	}
+
+	listp.MenubarBegin()
+	defer listp.MenubarEnd()
+
+	if listingSearch.ed.Active {
+		for _, k := range listp.Input().Keyboard.Keys {
+			if k.Modifiers == 0 && k.Code == key.CodeEscape {
+				listingSearch.mode = listingBarHidden
+				return
+			}
+		}
+	}
+
+	switch listingSearch.mode {
+	case listingBarSearch:
+		listp.Row(varRowHeight).Static(50, 0, 80, 60, 60, 120, 30)
+		listp.Label("Find:", "LC")
+		active := listingSearch.ed.Edit(listp)
+		updateListingMatches(string(listingSearch.ed.Buffer))
+		if len(listingSearch.matches) > 0 {
+			listp.Label(fmt.Sprintf("%d/%d", listingSearch.cur+1, len(listingSearch.matches)), "LC")
+		} else {
+			listp.Label("no match", "LC")
+		}
+		if listp.ButtonText("Prev") {
+			nextListingMatch(-1)
+		}
+		if listp.ButtonText("Next") || active&nucular.EditCommitted != 0 {
+			nextListingMatch(1)
+		}
+		if listp.ButtonText("In sources...") {
+			openFindInSources(listp.Master(), listingSearch.query)
+		}
+	case listingBarGotoLine:
+		listp.Row(varRowHeight).Static(50, 0, 30)
+		listp.Label("Line:", "LC")
+		active := listingSearch.ed.Edit(listp)
+		if active&nucular.EditCommitted != 0 {
+			if n, err := strconv.Atoi(strings.TrimSpace(string(listingSearch.ed.Buffer))); err == nil && listingPanel.file != "" {
+				listingPanel.pinnedLoc = &api.Location{File: listingPanel.file, Line: n}
+				go refreshState(refreshToSameFrame, clearNothing, nil)
+			}
+			listingSearch.mode = listingBarHidden
+		}
+	}
+
+	if listp.ButtonText("x") {
+		listingSearch.mode = listingBarHidden
+	}
+}
+
+// updateListingMatches finds all occurrences of query in the listing. The
+// search is case insensitive unless query contains upper case letters.
+func updateListingMatches(query string) {
+	if query == listingSearch.query && listingPanel.file == listingSearch.file && len(listingPanel.listing) == listingSearch.nlines {
+		return
+	}
+	queryChanged := query != listingSearch.query
+	listingSearch.query = query
+	listingSearch.file = listingPanel.file
+	listingSearch.nlines = len(listingPanel.listing)
+	listingSearch.matches = listingSearch.matches[:0]
+	if query == "" {
+		return
+	}
+
+	fold := strings.IndexFunc(query, unicode.IsUpper) < 0
+	if fold {
+		query = strings.ToLower(query)
+	}
+	qlen := utf8.RuneCountInString(query)
+	for i := range listingPanel.listing {
+		text := listingPanel.listing[i].text
+		if fold {
+			text = strings.ToLower(text)
+		}
+		for off := 0; ; {
+			j := strings.Index(text[off:], query)
+			if j < 0 {
+				break
+			}
+			col := utf8.RuneCountInString(text[:off+j])
+			listingSearch.matches = append(listingSearch.matches, listingMatch{i, col, qlen})
+			off += j + len(query)
+		}
+	}
+
+	if listingSearch.cur >= len(listingSearch.matches) || queryChanged {
+		listingSearch.cur = 0
+		listingSearch.scroll = len(listingSearch.matches) > 0
+	}
+}
+
+func nextListingMatch(dir int) {
+	n := len(listingSearch.matches)
+	if n == 0 {
+		return
+	}
+	listingSearch.cur = (listingSearch.cur + dir + n) % n
+	listingSearch.scroll = true
+}
+
+// drawListingMatches highlights the matches on line i, bounds are the
+// bounds of the widget that will display the text of the line.
+func drawListingMatches(w *nucular.Window, i int, bounds rect.Rect) {
+	if listingSearch.mode != listingBarSearch {
+		return
+	}
+	matches := listingSearch.matches
+	j := sort.Search(len(matches), func(j int) bool { return matches[j].line >= i })
+	style := w.Master().Style()
+	cmds := w.Commands()
+	for ; j < len(matches) && matches[j].line == i; j++ {
+		r := bounds
+		r.X += style.Text.Padding.X + matches[j].col*zeroWidth
+		r.W = matches[j].len * zeroWidth
+		c := matchColor
+		if j == listingSearch.cur {
+			c = curMatchColor
+		}
+		cmds.FillRect(r, 0, c)
+	}
+}
+
+// listingSearchScroll returns the line that should be scrolled into view
+// to show the current match.
+func listingSearchScroll() (line int, ok bool) {
+	if !listingSearch.scroll || listingSearch.mode != listingBarSearch || len(listingSearch.matches) == 0 {
+		return 0, false
+	}
+	listingSearch.scroll = false
+	return listingSearch.matches[listingSearch.cur].line, true
+}
+
+type sourceMatch struct {
+	file string
+	line int
+	text string
+}
+
+type findInSourcesWindow struct {
+	mu        sync.Mutex
+	ed        nucular.TextEditor
+	searching bool
+	results   []sourceMatch
+	vl        virtualList
+}
+
+const maxSourceMatches = 1000
+
+func openFindInSources(mw nucular.MasterWindow, query string) {
+	fw := &findInSourcesWindow{}
+	fw.ed.Flags = nucular.EditSelectable | nucular.EditSigEnter | nucular.EditClipboard
+	fw.ed.Buffer = []rune(query)
+	fw.ed.Cursor = len(fw.ed.Buffer)
+	fw.ed.Active = true
+	if query != "" {
+		fw.search(query)
+	}
+	mw.PopupOpen("Find in sources", popupFlags|nucular.WindowScalable, rect.Rect{100, 100, 700, 500}, true, fw.update)
+}
+
+func (fw *findInSourcesWindow) search(query string) {
+	fw.mu.Lock()
+	if fw.searching {
+		fw.mu.Unlock()
+		return
+	}
+	fw.searching = true
+	fw.results = nil
+	fw.mu.Unlock()
+
+	go func() {
+		mu.Lock()
+		sources := sourcesPanel.slice
+		mu.Unlock()
+
+		var results []sourceMatch
+	sourcesLoop:
+		for _, file := range sources {
+			fh, err := os.Open(file)
+			if err != nil {
+				continue
+			}
+			buf := bufio.NewScanner(fh)
+			lineno := 0
+			for buf.Scan() {
+				lineno++
+				if strings.Index(buf.Text(), query) >= 0 {
+					results = append(results, sourceMatch{file, lineno, strings.TrimSpace(expandTabs(buf.Text()))})
+					if len(results) >= maxSourceMatches {
+						fh.Close()
+						break sourcesLoop
+					}
+				}
+			}
+			fh.Close()
+		}
+		fw.mu.Lock()
+		fw.results = results
+		fw.searching = false
+		fw.mu.Unlock()
+		wnd.Changed()
+	}()
+}
+
+func (fw *findInSourcesWindow) update(w *nucular.Window) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	w.Row(varRowHeight).Static(50, 0, 100)
+	w.Label("Find:", "LC")
+	active := fw.ed.Edit(w)
+	search := w.ButtonText("Search") || active&nucular.EditCommitted != 0
+
+	w.Row(varRowHeight).Dynamic(1)
+	switch {
+	case fw.searching:
+		w.Label("Searching...", "LC")
+	case len(fw.results) >= maxSourceMatches:
+		w.Label(fmt.Sprintf("First %d matches", len(fw.results)), "LC")
+	default:
+		w.Label(fmt.Sprintf("%d matches", len(fw.results)), "LC")
+	}
+
+	w.Row(0).Dynamic(1)
+	if g := w.GroupBegin("find-results", 0); g != nil {
+		fw.vl.update(g, len(fw.results), varRowHeight, func(i int) {
+			m := &fw.results[i]
+			g.Row(varRowHeight).Dynamic(1)
+			selected := false
+			if g.SelectableLabel(fmt.Sprintf("%s:%d: %s", abbrevFileName(m.file), m.line, m.text), "LC", &selected) {
+				listingPanel.pinnedLoc = &api.Location{File: m.file, Line: m.line}
+				go refreshState(refreshToSameFrame, clearNothing, nil)
+			}
+		})
+		g.GroupEnd()
+	}
+
+	w.Row(varRowHeight).Static(0, 100)
+	w.Spacing(1)
+	if w.ButtonText("Close") {
+		w.Close()
+	}
+
+	if search && len(fw.ed.Buffer) > 0 {
+		fw.mu.Unlock()
+		fw.search(string(fw.ed.Buffer))
+		fw.mu.Lock()
+	}
+}
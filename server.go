@@ -7,8 +7,8 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/derekparker/delve/service/api"
 	"github.com/derekparker/delve/service/rpc2"
@@ -19,6 +19,8 @@ type ServerDescr struct {
 	connectString string
 	// stdout and stderr streams from server process
 	stdout, stderr io.ReadCloser
+	// stdin stream of the server process, passed to the target by delve
+	stdin io.WriteCloser
 	// server process
 	serverProcess *os.Process
 	// arguments for 'go' used to build the executable
@@ -33,6 +35,27 @@ type ServerDescr struct {
 	atStart bool
 	// connection to delve failed
 	connectionFailed bool
+	// identifies the program being debugged, used to save per-project
+	// state, empty when the executable is not known
+	project string
+	// debugging a core file, the target can not be run or modified
+	core bool
+
+	// subcommand that started the target: debug, run, exec or test, empty
+	// if gdlv didn't start the target
+	mode string
+	// file or package passed to 'go build' by the run subcommand
+	target string
+	// additional flags for 'go build' and build tags
+	buildFlags, tags string
+	// working directory and additional environment variables of the target
+	wd  string
+	env []string
+	// arguments passed to the target
+	args []string
+	// set when the run configuration changed in a way that requires
+	// starting a new delve process
+	restartServer bool
 }
 
 var BackendServer ServerDescr
@@ -67,40 +90,39 @@ func parseArguments() (descr ServerDescr) {
 			usage()
 		}
 		finish(false, "--headless", "attach", os.Args[2])
-	case "debug":
-		debugname()
-		descr.buildcmd = []string{"build", "-gcflags", "-N -l", "-o", descr.exe}
-		args := make([]string, 0, len(os.Args[2:])+3)
-		args = append(args, "--headless", "exec", descr.exe, "--")
-		args = append(args, os.Args[2:]...)
-		finish(true, args...)
-	case "run":
-		debugname()
-		descr.buildcmd = []string{"build", "-gcflags", "-N -l", "-o", descr.exe, os.Args[2]}
-		args := make([]string, 0, len(os.Args[3:])+3)
-		args = append(args, "--headless", "exec", descr.exe, "--")
-		args = append(args, os.Args[3:]...)
-		finish(true, args...)
-	case "exec":
-		if len(os.Args) < 3 {
+	case "core":
+		if len(os.Args) != 4 {
 			usage()
 		}
-		args := make([]string, 0, len(os.Args[3:])+4)
-		args = append(args, "--headless", "exec", os.Args[2], "--")
-		args = append(args, os.Args[3:]...)
-		finish(true, args...)
-	case "test":
-		debugname()
-		descr.buildcmd = []string{"test", "-gcflags", "-N -l", "-c", "-o", descr.exe}
-		args := make([]string, 0, len(os.Args[2:])+3)
-		args = append(args, "--headless", "exec", descr.exe, "--")
-		for _, arg := range os.Args[2:] {
-			if len(arg) > 0 && arg[0] == '-' {
-				arg = "-test." + arg[1:]
+		// project is left empty, breakpoints can not be set on core files
+		descr.core = true
+		finish(false, "--headless", "core", os.Args[2], os.Args[3])
+	case "debug", "run", "exec", "test":
+		descr.mode = os.Args[1]
+		args := descr.parseRunOptions(os.Args[2:])
+		switch descr.mode {
+		case "debug", "test":
+			debugname()
+			descr.project, _ = os.Getwd()
+		case "run":
+			if len(args) < 1 {
+				usage()
+			}
+			debugname()
+			descr.target = args[0]
+			descr.project, _ = filepath.Abs(args[0])
+			args = args[1:]
+		case "exec":
+			if len(args) < 1 {
+				usage()
 			}
-			args = append(args, arg)
+			descr.exe = args[0]
+			descr.project, _ = filepath.Abs(args[0])
+			args = args[1:]
 		}
-		finish(true, args...)
+		descr.setArgs(args)
+		descr.atStart = true
+		descr.updateCommandLine()
 	default:
 		usage()
 	}
@@ -108,6 +130,144 @@ func parseArguments() (descr ServerDescr) {
 	return
 }
 
+// parseRunOptions parses the options of the debug, run, exec and test
+// subcommands at the start of args and returns the remaining arguments.
+func (descr *ServerDescr) parseRunOptions(args []string) []string {
+	for len(args) > 0 {
+		var val string
+		switch args[0] {
+		case "--build-flags", "--tags", "--wd", "--env":
+			if len(args) < 2 {
+				usage()
+			}
+			val = args[1]
+		case "--":
+			return args[1:]
+		default:
+			return args
+		}
+		switch args[0] {
+		case "--build-flags":
+			descr.buildFlags = val
+		case "--tags":
+			descr.tags = val
+		case "--wd":
+			descr.wd = val
+		case "--env":
+			if !strings.Contains(val, "=") {
+				usage()
+			}
+			descr.env = append(descr.env, val)
+		}
+		args = args[2:]
+	}
+	return args
+}
+
+// setArgs sets the arguments passed to the target, flags passed to tests
+// are converted to the names used by test executables.
+func (descr *ServerDescr) setArgs(args []string) {
+	if descr.mode == "test" {
+		for i, arg := range args {
+			if len(arg) > 0 && arg[0] == '-' && !strings.HasPrefix(arg, "-test.") {
+				args[i] = "-test." + arg[1:]
+			}
+		}
+	}
+	descr.args = args
+}
+
+// canConfigure returns true if descr starts the target itself, so that its
+// build flags, working directory and environment can be changed.
+func (descr *ServerDescr) canConfigure() bool {
+	return descr.mode != ""
+}
+
+// updateCommandLine sets the arguments used to build the executable and to
+// start delve from the run configuration.
+func (descr *ServerDescr) updateCommandLine() {
+	descr.buildcmd = nil
+	switch descr.mode {
+	case "debug", "run":
+		descr.buildcmd = []string{"build"}
+	case "test":
+		descr.buildcmd = []string{"test", "-c"}
+	}
+	if descr.buildcmd != nil {
+		descr.buildcmd = append(descr.buildcmd, "-gcflags", "-N -l")
+		if descr.tags != "" {
+			descr.buildcmd = append(descr.buildcmd, "-tags", descr.tags)
+		}
+		descr.buildcmd = append(descr.buildcmd, splitQuotedFields(descr.buildFlags)...)
+		descr.buildcmd = append(descr.buildcmd, "-o", descr.exe)
+		if descr.target != "" {
+			descr.buildcmd = append(descr.buildcmd, descr.target)
+		}
+	}
+
+	descr.dlvargs = []string{"--headless"}
+	if descr.wd != "" {
+		descr.dlvargs = append(descr.dlvargs, "--wd", descr.wd)
+	}
+	descr.dlvargs = append(descr.dlvargs, "exec", descr.exe, "--")
+	descr.dlvargs = append(descr.dlvargs, descr.args...)
+}
+
+// splitQuotedFields splits s around spaces, except spaces inside single or
+// double quotes, and removes the quotes.
+func splitQuotedFields(s string) []string {
+	var r []string
+	var cur []rune
+	var quote rune
+	infield := false
+	for _, ch := range s {
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else {
+				cur = append(cur, ch)
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+			infield = true
+		case ch == ' ' || ch == '\t':
+			if infield {
+				r = append(r, string(cur))
+				cur = cur[:0]
+				infield = false
+			}
+		default:
+			cur = append(cur, ch)
+			infield = true
+		}
+	}
+	if infield {
+		r = append(r, string(cur))
+	}
+	return r
+}
+
+// joinQuotedFields is the inverse of splitQuotedFields.
+func joinQuotedFields(v []string) string {
+	r := make([]string, len(v))
+	for i, s := range v {
+		switch {
+		case s != "" && !strings.ContainsAny(s, " \t'\""):
+			r[i] = s
+		case !strings.Contains(s, "'"):
+			r[i] = "'" + s + "'"
+		case !strings.Contains(s, "\""):
+			r[i] = "\"" + s + "\""
+		default:
+			// single quotes are written as "'" between single quoted parts,
+			// splitQuotedFields joins adjacent quoted parts
+			r[i] = "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+		}
+	}
+	return strings.Join(r, " ")
+}
+
 func parseListenString(listenstr string) string {
 	var scrollbackOut = editorWriter{&scrollbackEditor, false}
 
@@ -134,8 +294,7 @@ func (s *ServerDescr) Start() {
 func (descr *ServerDescr) stdoutProcess() {
 	var scrollbackOut = editorWriter{&scrollbackEditor, true}
 
-	bucket := 0
-	t0 := time.Now()
+	// the first line is written by delve, the rest by the target
 	first := true
 	scan := bufio.NewScanner(descr.stdout)
 	for scan.Scan() {
@@ -144,27 +303,7 @@ func (descr *ServerDescr) stdoutProcess() {
 			descr.connectTo()
 			first = false
 		} else {
-			mu.Lock()
-			if silenced {
-				mu.Unlock()
-				continue
-			}
-			mu.Unlock()
-			now := time.Now()
-			if now.Sub(t0) > 500*time.Millisecond {
-				t0 = now
-				bucket = 0
-			}
-			bucket += len(scan.Text())
-			if bucket > scrollbackLowMark {
-				mu.Lock()
-				silenced = true
-				mu.Unlock()
-				fmt.Fprintf(&scrollbackOut, "too much output in 500ms (%d), output silenced\n", bucket)
-				bucket = 0
-				continue
-			}
-			fmt.Fprintln(&scrollbackOut, scan.Text())
+			appendOutput(scan.Text(), false)
 		}
 	}
 	if err := scan.Err(); err != nil {
@@ -178,38 +317,89 @@ func (descr *ServerDescr) stdoutProcess() {
 
 func (descr *ServerDescr) stderrProcess() {
 	var scrollbackOut = editorWriter{&scrollbackEditor, true}
-	_, err := io.Copy(&scrollbackOut, descr.stderr)
-	if err != nil {
+	scan := bufio.NewScanner(descr.stderr)
+	for scan.Scan() {
+		mu.Lock()
+		connected := client != nil
+		mu.Unlock()
+		if connected {
+			appendOutput(scan.Text(), true)
+		} else {
+			// errors of delve before the target starts
+			fmt.Fprintln(&scrollbackOut, scan.Text())
+		}
+	}
+	if err := scan.Err(); err != nil {
 		fmt.Fprintf(&scrollbackOut, "Error reading stderr: %v\n", err)
 	}
 }
 
 func (descr *ServerDescr) Rebuild() {
 	sw := &editorWriter{&scrollbackEditor, true}
+	descr.build(sw)
+	if descr.serverProcess == nil && descr.buildok {
+		descr.startDelve(sw)
+	}
+}
+
+func (descr *ServerDescr) build(sw io.Writer) {
 	descr.buildok = true
 	if descr.buildcmd != nil {
 		fmt.Fprintf(sw, "Compiling...")
 		out, err := exec.Command("go", descr.buildcmd...).CombinedOutput()
 		fmt.Fprintf(sw, "done\n")
-		s := string(out)
 		if err != nil {
 			descr.buildok = false
-			s += fmt.Sprintf("\n%v\n", err)
 		}
-		io.WriteString(sw, s)
+		setBuildResult(sw, string(out), err)
 	}
-	if descr.serverProcess == nil && descr.buildok {
-		cmd := exec.Command("dlv", descr.dlvargs...)
-		descr.stdout, _ = cmd.StdoutPipe()
-		descr.stderr, _ = cmd.StderrPipe()
-		err := cmd.Start()
-		if err != nil {
-			io.WriteString(sw, fmt.Sprintf("Could not start delve: %v\n", err))
-		}
-		descr.serverProcess = cmd.Process
-		go descr.stdoutProcess()
-		go descr.stderrProcess()
+}
+
+func (descr *ServerDescr) startDelve(sw io.Writer) {
+	cmd := exec.Command("dlv", descr.dlvargs...)
+	if len(descr.env) > 0 {
+		// the target inherits the environment of delve
+		cmd.Env = append(os.Environ(), descr.env...)
+	}
+	descr.stdout, _ = cmd.StdoutPipe()
+	descr.stderr, _ = cmd.StderrPipe()
+	descr.stdin, _ = cmd.StdinPipe()
+	err := cmd.Start()
+	if err != nil {
+		io.WriteString(sw, fmt.Sprintf("Could not start delve: %v\n", err))
 	}
+	descr.serverProcess = cmd.Process
+	go descr.stdoutProcess()
+	go descr.stderrProcess()
+}
+
+// restartDelve rebuilds the target and starts a new delve process, used
+// when the target must be restarted in a way that delve doesn't support.
+// Breakpoints are recorded in FrozenBreakpoints and then restored by
+// connectTo.
+func (descr *ServerDescr) restartDelve(out io.Writer) {
+	descr.build(out)
+	if !descr.buildok {
+		return
+	}
+
+	updateFrozenBreakpoints()
+	saveFrozenBreakpoints()
+
+	mu.Lock()
+	oldclient := client
+	client = nil
+	mu.Unlock()
+	if oldclient != nil {
+		oldclient.Detach(true)
+	}
+	if descr.serverProcess != nil {
+		descr.serverProcess.Wait()
+	}
+	descr.restartServer = false
+
+	fmt.Fprintf(out, "Restarting delve\n")
+	descr.startDelve(out)
 }
 
 func (descr *ServerDescr) connectTo() {
@@ -249,6 +439,13 @@ func (descr *ServerDescr) connectTo() {
 
 	fmt.Fprintf(&scrollbackOut, "done\n")
 
+	if descr.core {
+		// breakpoints can not be set on core files
+		fmt.Fprintf(&scrollbackOut, "Debugging a core file, commands that run or modify the target are disabled\n")
+	} else {
+		restoreSavedBreakpoints(&scrollbackOut)
+	}
+
 	if descr.atStart {
 		continueToRuntimeMain()
 	}
@@ -266,6 +463,10 @@ func (descr *ServerDescr) connectTo() {
 	}
 
 	refreshState(refreshToFrameZero, clearStop, state)
+
+	if client != nil {
+		runInitScripts()
+	}
 }
 
 func continueToRuntimeMain() {
@@ -0,0 +1,47 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitQuotedFields(t *testing.T) {
+	tests := []struct {
+		in  string
+		out []string
+	}{
+		{"", nil},
+		{"a b\tc", []string{"a", "b", "c"}},
+		{"  a   b  ", []string{"a", "b"}},
+		{`'a b' "c d"`, []string{"a b", "c d"}},
+		{`"it's" 'say "hi"'`, []string{"it's", `say "hi"`}},
+		{`a'b c'd`, []string{"ab cd"}},
+		{`'' ""`, []string{"", ""}},
+		{`'a'"'"'b'`, []string{"a'b"}},
+	}
+	for _, tc := range tests {
+		if out := splitQuotedFields(tc.in); !reflect.DeepEqual(out, tc.out) {
+			t.Errorf("splitQuotedFields(%q) = %q, expected %q", tc.in, out, tc.out)
+		}
+	}
+}
+
+func TestJoinQuotedFields(t *testing.T) {
+	tests := [][]string{
+		{"a", "b"},
+		{"a b", "c\td"},
+		{""},
+		{"it's"},
+		{`say "hi"`},
+		{`it's "quoted"`},
+		{`'"`, `"'`, "'"},
+	}
+	for _, v := range tests {
+		s := joinQuotedFields(v)
+		if out := splitQuotedFields(s); !reflect.DeepEqual(out, v) {
+			t.Errorf("splitQuotedFields(joinQuotedFields(%q)) = %q (joined %q)", v, out, s)
+		}
+	}
+}
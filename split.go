@@ -39,6 +39,9 @@ const (
 	infoFuncs       = "Functions"
 	infoTypes       = "Types"
 	infoExprs       = "Expressions"
+	infoMemory      = "Memory"
+	infoOutput      = "Output"
+	infoBuild       = "Build"
 )
 
 var infoNameToFunc = map[string]func(w *nucular.Window){
@@ -56,10 +59,13 @@ var infoNameToFunc = map[string]func(w *nucular.Window){
 	infoFuncs:       funcsPanel.update,
 	infoTypes:       typesPanel.update,
 	infoExprs:       updateExprs,
+	infoMemory:      updateMemory,
+	infoOutput:      updateOutput,
+	infoBuild:       updateBuild,
 }
 
 var infoModes = []string{
-	infoCommand, infoListing, infoDisassembly, infoGoroutines, infoStacktrace, infoLocals, infoGlobal, infoExprs, infoBps, infoThreads, infoRegisters, infoSources, infoFuncs, infoTypes,
+	infoCommand, infoListing, infoDisassembly, infoGoroutines, infoStacktrace, infoLocals, infoGlobal, infoExprs, infoBps, infoThreads, infoRegisters, infoSources, infoFuncs, infoTypes, infoMemory, infoOutput, infoBuild,
 }
 
 var codeToInfoMode = map[byte]string{
@@ -77,6 +83,9 @@ var codeToInfoMode = map[byte]string{
 	't': infoTypes,
 	'T': infoThreads,
 	'e': infoExprs,
+	'm': infoMemory,
+	'O': infoOutput,
+	'b': infoBuild,
 }
 
 var infoModeToCode = map[string]byte{}
@@ -349,6 +358,11 @@ func (p *panel) commandToolbar(sw *nucular.Window) {
 	case client == nil:
 		p.splitMenu(sw)
 
+	case BackendServer.core:
+		p.splitMenu(sw)
+		sw.LayoutSetWidth(200)
+		sw.Label("Core file (read only)", "LC")
+
 	case running:
 		p.splitMenu(sw)
 		sw.LayoutSetWidth(controlBtnWidth)
@@ -378,6 +392,10 @@ func (p *panel) commandToolbar(sw *nucular.Window) {
 		sw.LayoutSetWidth(controlBtnWidth)
 		cmdbtn(stepoutIcon, "stepout")
 	}
+	if buildPanel.built && !buildPanel.ok {
+		sw.LayoutSetWidth(100)
+		sw.LabelColored("Build failed", "CC", buildFailedColor)
+	}
 	p.toolbarHeaderCombo(sw)
 }
 
@@ -415,6 +433,18 @@ func (p *panel) dosplit(kind panelKind) {
 	p.parent = newpanel
 }
 
+// shows returns true if p, or one of its descendants, is an info panel
+// showing mode.
+func (p *panel) shows(mode string) bool {
+	if p == nil {
+		return false
+	}
+	if p.kind == infoPanelKind {
+		return infoModes[p.infoMode] == mode
+	}
+	return p.child[0].shows(mode) || p.child[1].shows(mode)
+}
+
 func (p *panel) idx(child *panel) int {
 	for i := range p.child {
 		if p.child[i] == child {
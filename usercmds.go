@@ -0,0 +1,255 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// User defined commands are stored in the configuration and expand into a
+// list of commands separated by ';'. Aliases append their arguments to the
+// last command of the list, macros replace $1...$9 with their arguments
+// and $* with all of them.
+
+// maxUserCommandDepth limits how deeply user defined commands can call
+// each other, to stop user commands that call themselves.
+const maxUserCommandDepth = 20
+
+var userCommandDepth int
+
+func isBuiltinCommand(name string) bool {
+	for _, cmd := range cmds.cmds {
+		if cmd.match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// findUserCommand returns the function executing the user defined command
+// called name, or nil if there is no such command.
+func findUserCommand(name string) cmdfunc {
+	if body, ok := conf.Aliases[name]; ok {
+		return func(out io.Writer, args string) error {
+			cmdlist := splitCommands(body)
+			if args != "" && len(cmdlist) > 0 {
+				cmdlist[len(cmdlist)-1] += " " + args
+			}
+			return runUserCommand(out, name, cmdlist)
+		}
+	}
+	if body, ok := conf.Macros[name]; ok {
+		return func(out io.Writer, args string) error {
+			body, err := expandMacro(body, args)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			return runUserCommand(out, name, splitCommands(body))
+		}
+	}
+	return nil
+}
+
+func runUserCommand(out io.Writer, name string, cmdlist []string) error {
+	if userCommandDepth >= maxUserCommandDepth {
+		return fmt.Errorf("%s: too many nested user commands", name)
+	}
+	userCommandDepth++
+	lastCmd := cmds.lastCmd
+	defer func() {
+		userCommandDepth--
+		// repeat the user command, not the last command it executed, when
+		// enter is pressed
+		cmds.lastCmd = lastCmd
+	}()
+
+	for _, cmdstr := range cmdlist {
+		cmdstr, args := parseCommand(cmdstr)
+		if err := cmds.Call(cmdstr, args, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitCommands splits body on the ';' characters that are not inside a
+// string or character literal.
+func splitCommands(body string) []string {
+	var r []string
+	var quote rune
+	escaped := false
+	start := 0
+	for i, ch := range body {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			switch {
+			case ch == '\\' && quote != '`':
+				escaped = true
+			case ch == quote:
+				quote = 0
+			}
+		case ch == '"' || ch == '\'' || ch == '`':
+			quote = ch
+		case ch == ';':
+			if s := strings.TrimSpace(body[start:i]); s != "" {
+				r = append(r, s)
+			}
+			start = i + 1
+		}
+	}
+	if s := strings.TrimSpace(body[start:]); s != "" {
+		r = append(r, s)
+	}
+	return r
+}
+
+// expandMacro replaces $1...$9 in body with the corresponding argument and
+// $* with args. Arguments are not substituted inside string literals.
+func expandMacro(body, args string) (string, error) {
+	fields := strings.Fields(args)
+	var buf bytes.Buffer
+	var quote byte
+	escaped := false
+	for i := 0; i < len(body); i++ {
+		ch := body[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			switch {
+			case ch == '\\' && quote != '`':
+				escaped = true
+			case ch == quote:
+				quote = 0
+			}
+		case ch == '"' || ch == '\'' || ch == '`':
+			quote = ch
+		case ch == '$' && i+1 < len(body):
+			switch next := body[i+1]; {
+			case next == '*':
+				buf.WriteString(args)
+				i++
+				continue
+			case next >= '1' && next <= '9':
+				n := int(next - '0')
+				if n > len(fields) {
+					return "", fmt.Errorf("argument $%d missing", n)
+				}
+				buf.WriteString(fields[n-1])
+				i++
+				continue
+			}
+		}
+		buf.WriteByte(ch)
+	}
+	return buf.String(), nil
+}
+
+func aliasCommand(out io.Writer, args string) error {
+	if args == "" {
+		return printUserCommands(out)
+	}
+	name, body := parseCommand(args)
+	if name == "-d" {
+		return deleteUserCommand(body)
+	}
+	if body == "" {
+		return errors.New("not enough arguments")
+	}
+	if err := checkUserCommandName(name); err != nil {
+		return err
+	}
+	delete(conf.Macros, name)
+	conf.Aliases[name] = body
+	saveConfiguration()
+	return nil
+}
+
+func defineCommand(out io.Writer, args string) error {
+	name, body := parseCommand(args)
+	if body == "" {
+		return errors.New("not enough arguments")
+	}
+	if strings.HasPrefix(body, "{") {
+		if !strings.HasSuffix(body, "}") {
+			return errors.New("missing closing brace")
+		}
+		body = strings.TrimSpace(body[1 : len(body)-1])
+	}
+	if err := checkUserCommandName(name); err != nil {
+		return err
+	}
+	delete(conf.Aliases, name)
+	conf.Macros[name] = body
+	saveConfiguration()
+	return nil
+}
+
+func checkUserCommandName(name string) error {
+	if isBuiltinCommand(name) {
+		return fmt.Errorf("%q is already a command", name)
+	}
+	if name == "" || strings.IndexAny(name, "$;{}\"'`") >= 0 {
+		return fmt.Errorf("invalid command name %q", name)
+	}
+	return nil
+}
+
+func deleteUserCommand(name string) error {
+	_, isalias := conf.Aliases[name]
+	_, ismacro := conf.Macros[name]
+	if !isalias && !ismacro {
+		return fmt.Errorf("unknown user command %q", name)
+	}
+	delete(conf.Aliases, name)
+	delete(conf.Macros, name)
+	saveConfiguration()
+	return nil
+}
+
+// userCommandNames returns the sorted names of all user defined commands.
+func userCommandNames() []string {
+	r := make([]string, 0, len(conf.Aliases)+len(conf.Macros))
+	for name := range conf.Aliases {
+		r = append(r, name)
+	}
+	for name := range conf.Macros {
+		r = append(r, name)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// userCommandHelp returns the definition of a user defined command.
+func userCommandHelp(name string) (string, bool) {
+	if body, ok := conf.Aliases[name]; ok {
+		return fmt.Sprintf("alias %s %s", name, body), true
+	}
+	if body, ok := conf.Macros[name]; ok {
+		return fmt.Sprintf("define %s { %s }", name, body), true
+	}
+	return "", false
+}
+
+func printUserCommands(out io.Writer) error {
+	names := userCommandNames()
+	if len(names) == 0 {
+		fmt.Fprintln(out, "No user defined commands")
+		return nil
+	}
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 0, ' ', 0)
+	for _, name := range names {
+		h, _ := userCommandHelp(name)
+		fmt.Fprintf(w, "    %s \t %s\n", name, h)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,55 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandMacro(t *testing.T) {
+	tests := []struct {
+		body, args string
+		out        string
+		err        bool
+	}{
+		{"print $1", "x", "print x", false},
+		{"print $1.a + $2", "x y", "print x.a + y", false},
+		{"print $*", "x  y", "print x  y", false},
+		{"print $2", "x", "", true},
+		{"print $0 $a $", "x", "print $0 $a $", false},
+		{`print "$1"`, "x", `print "$1"`, false},
+		{`print '$1', $1`, "x", `print '$1', x`, false},
+		{"print `$1\\`, $1", "x", "print `$1\\`, x", false},
+		{`print "\"$1", $1`, "x", `print "\"$1", x`, false},
+	}
+	for _, tc := range tests {
+		out, err := expandMacro(tc.body, tc.args)
+		if (err != nil) != tc.err {
+			t.Errorf("expandMacro(%q, %q): unexpected error %v", tc.body, tc.args, err)
+			continue
+		}
+		if out != tc.out {
+			t.Errorf("expandMacro(%q, %q) = %q, expected %q", tc.body, tc.args, out, tc.out)
+		}
+	}
+}
+
+func TestSplitCommands(t *testing.T) {
+	tests := []struct {
+		body string
+		out  []string
+	}{
+		{"print a", []string{"print a"}},
+		{"print a; print b ;; ", []string{"print a", "print b"}},
+		{`print "a;b"; print 'c;d'`, []string{`print "a;b"`, `print 'c;d'`}},
+		{`print "a\";b"; next`, []string{`print "a\";b"`, "next"}},
+		{"print `a\\`; next", []string{"print `a\\`", "next"}},
+		{"", nil},
+	}
+	for _, tc := range tests {
+		if out := splitCommands(tc.body); !reflect.DeepEqual(out, tc.out) {
+			t.Errorf("splitCommands(%q) = %q, expected %q", tc.body, out, tc.out)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"github.com/aarzilli/nucular"
+)
+
+// virtualList lays out long lists of rows that all have the same height.
+// Only the rows that intersect the visible area of the window are laid out,
+// the others are replaced by empty space of the same size.
+type virtualList struct {
+	// y coordinate of the first row, relative to the top of the scrollable
+	// area, as measured during the last frame
+	start int
+	// distance between the top of two consecutive rows
+	pitch int
+	// height of the visible area during the last frame
+	height int
+}
+
+// update lays out a list of n rows of the specified height, rowfn is
+// called to lay out each visible row and must start a new row of height
+// rowheight.
+func (vl *virtualList) update(w *nucular.Window, n, rowheight int, rowfn func(i int)) {
+	style := w.Master().Style()
+	spacing := style.GroupWindow.Spacing.Y
+	vl.pitch = int(float64(rowheight)*style.Scaling) + spacing
+	vl.height = w.Bounds.H
+
+	top := w.Scrollbar.Y - vl.start
+	first := top / vl.pitch
+	last := (top+vl.height)/vl.pitch + 1
+	if first < 0 {
+		first = 0
+	}
+	if first > n {
+		first = n
+	}
+	if last > n {
+		last = n
+	}
+	if last < first {
+		last = first
+	}
+
+	if first > 0 {
+		w.RowScaled(first*vl.pitch - spacing).Dynamic(1)
+		vl.start = w.At().Y
+		w.Spacing(1)
+	}
+
+	for i := first; i < last; i++ {
+		rowfn(i)
+		if i == 0 {
+			vl.start = w.At().Y
+		}
+	}
+
+	if last < n {
+		w.RowScaled((n-last)*vl.pitch - spacing).Dynamic(1)
+		w.Spacing(1)
+	}
+}
+
+// center returns the scrollbar position that centers row i, if row i is
+// not visible.
+func (vl *virtualList) center(w *nucular.Window, i int) (scrollbary int, ok bool) {
+	if vl.pitch == 0 {
+		return 0, false
+	}
+	y := vl.start + i*vl.pitch
+	if y >= w.Scrollbar.Y && y+vl.pitch <= w.Scrollbar.Y+vl.height {
+		return 0, false
+	}
+	scrollbary = y - vl.height/2
+	if scrollbary < 0 {
+		scrollbary = 0
+	}
+	return scrollbary, true
+}
@@ -0,0 +1,136 @@
+// Copyright 2016, Gdlv Authors
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aarzilli/nucular"
+)
+
+// The source files of the main module are polled for changes, when they
+// become newer than the executable gdlv offers to rebuild and restart the
+// target, or does it automatically if conf.AutoRestart is set.
+
+const sourceWatchInterval = time.Second
+
+// sourceWatch is protected by mu.
+var sourceWatch = struct {
+	// modification time of the newest source file, when it's newer than
+	// the executable
+	changed time.Time
+	// the bar was dismissed for changes up to this time
+	dismissed time.Time
+	// an automatic restart was attempted for changes up to this time
+	autoRestarted time.Time
+}{}
+
+// mainModuleDir returns the directory containing the main package of the
+// target, or the root of its module if it has one.
+func mainModuleDir() string {
+	dir := BackendServer.project
+	if fi, err := os.Stat(dir); err == nil && !fi.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// watchSources polls the source files of the main module for changes,
+// it only runs when gdlv builds the target.
+func watchSources() {
+	if BackendServer.buildcmd == nil || BackendServer.core {
+		return
+	}
+	root := mainModuleDir() + string(filepath.Separator)
+	var last, lastModExe time.Time
+	for range time.Tick(sourceWatchInterval) {
+		mu.Lock()
+		c := client
+		idle := c != nil && !running
+		sources := sourcesPanel.slice
+		mu.Unlock()
+		if !idle {
+			continue
+		}
+
+		var newest time.Time
+		for _, file := range sources {
+			if !strings.HasPrefix(file, root) {
+				continue
+			}
+			if fi, err := os.Stat(file); err == nil && fi.ModTime().After(newest) {
+				newest = fi.ModTime()
+			}
+		}
+
+		if newest.After(lastModExe) {
+			// the executable could have been rebuilt since the last check
+			lastModExe = c.LastModified()
+		}
+
+		// wait until the files stop changing, saving can touch many of them
+		stable := newest.Equal(last)
+		last = newest
+
+		mu.Lock()
+		old := sourceWatch.changed
+		if newest.After(lastModExe) {
+			sourceWatch.changed = newest
+		} else {
+			sourceWatch.changed = time.Time{}
+		}
+		autoRestart := conf.AutoRestart && stable && sourceWatch.changed.After(sourceWatch.autoRestarted)
+		if autoRestart {
+			sourceWatch.autoRestarted = sourceWatch.changed
+		}
+		changed := !old.Equal(sourceWatch.changed)
+		mu.Unlock()
+		if changed {
+			wnd.Changed()
+		}
+		if autoRestart {
+			restartForSources()
+		}
+	}
+}
+
+// restartForSources rebuilds and restarts the target, breakpoints are
+// moved by restart like they are when it's called from the command line.
+func restartForSources() {
+	scrollbackOut := editorWriter{&scrollbackEditor, true}
+	fmt.Fprintf(&scrollbackOut, "%s restart\n", currentPrompt())
+	executeCommand("restart")
+}
+
+// sourcesChangedBar shows the bar offering to restart the target after its
+// sources changed.
+func sourcesChangedBar(w *nucular.Window) {
+	if sourceWatch.changed.IsZero() || !sourceWatch.changed.After(sourceWatch.dismissed) || running {
+		return
+	}
+	w.Row(varRowHeight).Static(0, 150, 120, 80)
+	w.Label("Sources changed – rebuild and restart?", "LC")
+	if w.ButtonText("Rebuild and restart") {
+		sourceWatch.autoRestarted = sourceWatch.changed
+		go restartForSources()
+	}
+	if w.CheckboxText("Automatically", &conf.AutoRestart) {
+		saveConfiguration()
+	}
+	if w.ButtonText("Dismiss") {
+		sourceWatch.dismissed = sourceWatch.changed
+	}
+}